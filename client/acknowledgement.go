@@ -0,0 +1,139 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+const defaultAcknowledgementTTL = 7 * 24 * time.Hour
+
+var ErrInvalidAcknowledgementToken = errors.New("invalid or expired acknowledgement token")
+
+// AckSigner issues and verifies tokens that allow the holder to acknowledge
+// one alert until the token expires
+type AckSigner struct {
+	secret []byte
+	ttl    time.Duration
+	clock  clock.Clock
+}
+
+func NewAckSigner(config Acknowledgement, clock clock.Clock) *AckSigner {
+	ttl := defaultAcknowledgementTTL
+	if config.TTLSeconds != 0 {
+		ttl = time.Duration(config.TTLSeconds) * time.Second
+	}
+	return &AckSigner{secret: []byte(config.Secret), ttl: ttl, clock: clock}
+}
+
+func (s *AckSigner) Sign(fingerprint string) string {
+	expiry := strconv.FormatInt(s.clock.Now().Add(s.ttl).Unix(), 10)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fingerprint + "." + expiry))
+	return payload + "." + s.signature(payload)
+}
+
+// Verify returns the fingerprint of the alert the token acknowledges
+func (s *AckSigner) Verify(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(s.signature(parts[0]))) {
+		return "", ErrInvalidAcknowledgementToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidAcknowledgementToken
+	}
+
+	fields := strings.Split(string(payload), ".")
+	if len(fields) != 2 {
+		return "", ErrInvalidAcknowledgementToken
+	}
+	expiry, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || !s.clock.Now().Before(time.Unix(expiry, 0)) {
+		return "", ErrInvalidAcknowledgementToken
+	}
+
+	return fields[0], nil
+}
+
+// URL returns the acknowledgement link for the alert
+func (s *AckSigner) URL(baseURL, fingerprint string) (string, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return errs(err)
+	}
+	q := u.Query()
+	q.Set("token", s.Sign(fingerprint))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (s *AckSigner) signature(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+var acknowledgementFormTemplate = template.Must(template.New("acknowledgementForm").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Acknowledge service alert</title></head>
+<body>
+<form method="POST">
+<input type="hidden" name="token" value="{{.}}">
+<button type="submit">Acknowledge alert</button>
+</form>
+</body>
+</html>
+`))
+
+// NewAcknowledgementHandler serves the acknowledgement links rendered into
+// alert emails. GET shows a confirmation form, so that link scanners in mail
+// clients cannot acknowledge alerts, and POST marks the alert acknowledged.
+func NewAcknowledgementHandler(signer *AckSigner, store StateStore, clock clock.Clock, logger *log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "GET" && req.Method != "POST" {
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := req.FormValue("token")
+		fingerprint, err := signer.Verify(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+
+		if req.Method == "GET" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			acknowledgementFormTemplate.Execute(w, token)
+			return
+		}
+
+		if err := store.Acknowledge(fingerprint, clock.Now()); err != nil {
+			logger.Printf("Failed to acknowledge alert %s: %s", fingerprint, err)
+			http.Error(w, "failed to acknowledge alert", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Printf("Alert %s acknowledged", fingerprint)
+		fmt.Fprintln(w, "Alert acknowledged")
+	})
+}
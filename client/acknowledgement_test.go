@@ -0,0 +1,148 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Acknowledgement", func() {
+	var (
+		clock  *fakeClock
+		signer *AckSigner
+	)
+
+	BeforeEach(func() {
+		clock = newFakeClock(time.Date(2016, 10, 1, 0, 0, 0, 0, time.UTC))
+		signer = NewAckSigner(Acknowledgement{Secret: "some-secret", TTLSeconds: 3600}, clock)
+	})
+
+	Describe("AckSigner", func() {
+		It("verifies tokens it signed", func() {
+			Expect(signer.Verify(signer.Sign("fingerprint"))).To(Equal("fingerprint"))
+		})
+
+		It("rejects expired tokens", func() {
+			token := signer.Sign("fingerprint")
+			clock.Increment(time.Hour)
+
+			_, err := signer.Verify(token)
+			Expect(err).To(Equal(ErrInvalidAcknowledgementToken))
+		})
+
+		It("rejects tokens signed with another secret", func() {
+			otherSigner := NewAckSigner(Acknowledgement{Secret: "other-secret"}, clock)
+
+			_, err := signer.Verify(otherSigner.Sign("fingerprint"))
+			Expect(err).To(Equal(ErrInvalidAcknowledgementToken))
+		})
+
+		It("rejects malformed tokens", func() {
+			_, err := signer.Verify("not-a-token")
+			Expect(err).To(Equal(ErrInvalidAcknowledgementToken))
+		})
+
+		It("builds the acknowledgement URL", func() {
+			ackURL, err := signer.URL("https://alerts.example.com/acknowledge", "fingerprint")
+			Expect(err).NotTo(HaveOccurred())
+
+			parsed, err := url.Parse(ackURL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed.Path).To(Equal("/acknowledge"))
+			Expect(signer.Verify(parsed.Query().Get("token"))).To(Equal("fingerprint"))
+		})
+	})
+
+	Describe("handler", func() {
+		var (
+			store   *MemoryStateStore
+			handler http.Handler
+		)
+
+		BeforeEach(func() {
+			store = NewMemoryStateStore()
+			handler = NewAcknowledgementHandler(signer, store, clock, log.New(GinkgoWriter, "", 0))
+		})
+
+		It("shows a confirmation form without acknowledging", func() {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/acknowledge?token="+signer.Sign("fingerprint"), nil))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Body.String()).To(ContainSubstring(`<form method="POST">`))
+			Expect(store.Acknowledged("fingerprint")).To(BeFalse())
+		})
+
+		It("acknowledges the alert on POST", func() {
+			form := url.Values{"token": {signer.Sign("fingerprint")}}
+			req := httptest.NewRequest("POST", "/acknowledge", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(store.Acknowledged("fingerprint")).To(BeTrue())
+		})
+
+		It("rejects invalid tokens", func() {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/acknowledge?token=forged", nil))
+
+			Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		})
+	})
+
+	Describe("FileStateStore", func() {
+		It("remembers acknowledged alerts across instances", func() {
+			dir, err := ioutil.TempDir("", "state")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "state.json")
+
+			Expect(NewFileStateStore(path).Acknowledged("fingerprint")).To(BeFalse())
+			Expect(NewFileStateStore(path).Acknowledge("fingerprint", clock.Now())).To(Succeed())
+			Expect(NewFileStateStore(path).Acknowledged("fingerprint")).To(BeTrue())
+		})
+
+		It("remembers firing alerts until they are resolved", func() {
+			dir, err := ioutil.TempDir("", "state")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "state.json")
+
+			Expect(NewFileStateStore(path).Firing("fingerprint")).To(BeFalse())
+			Expect(NewFileStateStore(path).Resolve("fingerprint")).To(BeFalse())
+			Expect(NewFileStateStore(path).Fire("fingerprint", clock.Now())).To(Succeed())
+			Expect(NewFileStateStore(path).Firing("fingerprint")).To(BeTrue())
+			Expect(NewFileStateStore(path).Resolve("fingerprint")).To(BeTrue())
+			Expect(NewFileStateStore(path).Firing("fingerprint")).To(BeFalse())
+			Expect(NewFileStateStore(path).Resolve("fingerprint")).To(BeFalse())
+		})
+
+		It("forgets acknowledgements when alerts are resolved", func() {
+			dir, err := ioutil.TempDir("", "state")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "state.json")
+
+			Expect(NewFileStateStore(path).Acknowledge("fingerprint", clock.Now())).To(Succeed())
+			Expect(NewFileStateStore(path).Resolve("fingerprint")).To(BeFalse())
+			Expect(NewFileStateStore(path).Acknowledged("fingerprint")).To(BeFalse())
+		})
+	})
+})
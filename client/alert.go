@@ -0,0 +1,106 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityInfo:     "info",
+	SeverityWarning:  "warning",
+	SeverityCritical: "critical",
+}
+
+func ParseSeverity(name string) (Severity, error) {
+	for severity, severityName := range severityNames {
+		if strings.EqualFold(name, severityName) {
+			return severity, nil
+		}
+	}
+	return SeverityInfo, fmt.Errorf("unknown severity: '%s'", name)
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	severity, err := ParseSeverity(string(text))
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
+type Alert struct {
+	Product           string            `json:"product"`
+	Subject           string            `json:"subject"`
+	ServiceInstanceID string            `json:"service_instance,omitempty"`
+	Content           string            `json:"content"`
+	Severity          Severity          `json:"severity"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Resolved          bool              `json:"resolved,omitempty"`
+	// OccurredAt is when the problem was detected, which can be well before
+	// the alert is sent. The zero time means it is not known.
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (a Alert) Validate() error {
+	if a.Product == "" {
+		return errors.New("product is required")
+	}
+	return nil
+}
+
+// Fingerprint identifies an alert across repeated sends. It does not include
+// the content or severity, which may change while the alert is ongoing.
+func (a Alert) Fingerprint() string {
+	var labels []string
+	for key, value := range a.Labels {
+		labels = append(labels, key+"="+value)
+	}
+	sort.Strings(labels)
+
+	hash := sha256.New()
+	for _, field := range append([]string{a.Product, a.ServiceInstanceID, a.Subject}, labels...) {
+		fmt.Fprintf(hash, "%s\x00", field)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// Destination is a Cloud Foundry space whose developers receive alert emails
+type Destination struct {
+	CFOrg   string `yaml:"cf_org" json:"cf_org"`
+	CFSpace string `yaml:"cf_space" json:"cf_space"`
+}
+
+func (d Destination) String() string {
+	return fmt.Sprintf("org: %s, space: %s", d.CFOrg, d.CFSpace)
+}
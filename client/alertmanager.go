@@ -0,0 +1,205 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultAlertmanagerProduct         = `{{.CommonLabels.product | default "alertmanager"}}`
+	defaultAlertmanagerServiceInstance = `{{.CommonLabels.service_instance}}`
+	defaultAlertmanagerSubject         = `{{.CommonAnnotations.summary | default .GroupLabels.alertname | default .CommonLabels.alertname}}`
+	defaultAlertmanagerSeverity        = `{{.CommonLabels.severity}}`
+	defaultAlertmanagerContent         = `{{range .Alerts}}[{{.Status}}] {{.Annotations.summary | default .Labels.alertname}}
+{{with .Annotations.description}}{{.}}
+{{end}}Labels: {{labels .Labels}}
+Started at: {{.StartsAt.Format "2006-01-02T15:04:05Z07:00"}}
+{{if eq .Status "resolved"}}Resolved at: {{.EndsAt.Format "2006-01-02T15:04:05Z07:00"}}
+{{end}}{{with .GeneratorURL}}Source: {{.}}
+{{end}}
+{{end}}`
+)
+
+// AlertmanagerWebhook is the payload Prometheus Alertmanager posts to webhook receivers
+type AlertmanagerWebhook struct {
+	Version           string              `json:"version"`
+	GroupKey          string              `json:"groupKey"`
+	Status            string              `json:"status"`
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []AlertmanagerAlert `json:"alerts"`
+}
+
+type AlertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// AlertmanagerMapper turns a group of Alertmanager alerts into a single alert
+// using the configured templates
+type AlertmanagerMapper struct {
+	product         *template.Template
+	serviceInstance *template.Template
+	subject         *template.Template
+	content         *template.Template
+	severity        *template.Template
+}
+
+var alertmanagerTemplateFuncs = template.FuncMap{
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+	"labels": func(labels map[string]string) string {
+		var pairs []string
+		for key, value := range labels {
+			pairs = append(pairs, key+"="+value)
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ", ")
+	},
+}
+
+func NewAlertmanagerMapper(config Alertmanager) (*AlertmanagerMapper, error) {
+	mapper := &AlertmanagerMapper{}
+	templates := []struct {
+		name        string
+		text        string
+		defaultText string
+		target      **template.Template
+	}{
+		{"product", config.Product, defaultAlertmanagerProduct, &mapper.product},
+		{"service_instance", config.ServiceInstance, defaultAlertmanagerServiceInstance, &mapper.serviceInstance},
+		{"subject", config.Subject, defaultAlertmanagerSubject, &mapper.subject},
+		{"content", config.Content, defaultAlertmanagerContent, &mapper.content},
+		{"severity", config.Severity, defaultAlertmanagerSeverity, &mapper.severity},
+	}
+
+	for _, t := range templates {
+		text := t.text
+		if text == "" {
+			text = t.defaultText
+		}
+		parsed, err := template.New(t.name).Option("missingkey=zero").Funcs(alertmanagerTemplateFuncs).Parse(text)
+		if err != nil {
+			return nil, ConfigError{Section: "alertmanager", Err: err}
+		}
+		*t.target = parsed
+	}
+	return mapper, nil
+}
+
+// Map renders the templates for the group. Groups whose alerts have all
+// resolved become a resolved alert. Severities the client does not know,
+// including none at all, are treated as critical.
+func (m *AlertmanagerMapper) Map(webhook AlertmanagerWebhook) (Alert, error) {
+	alert := Alert{
+		Labels:     webhook.CommonLabels,
+		Resolved:   webhook.Status == "resolved",
+		OccurredAt: occurredAt(webhook),
+	}
+
+	fields := []struct {
+		template *template.Template
+		target   *string
+	}{
+		{m.product, &alert.Product},
+		{m.serviceInstance, &alert.ServiceInstanceID},
+		{m.subject, &alert.Subject},
+		{m.content, &alert.Content},
+	}
+	for _, field := range fields {
+		rendered, err := renderAlertmanagerTemplate(field.template, webhook)
+		if err != nil {
+			return Alert{}, err
+		}
+		*field.target = rendered
+	}
+
+	severity, err := renderAlertmanagerTemplate(m.severity, webhook)
+	if err != nil {
+		return Alert{}, err
+	}
+	alert.Severity, err = ParseSeverity(severity)
+	if err != nil {
+		alert.Severity = SeverityCritical
+	}
+
+	return alert, nil
+}
+
+// occurredAt is when the earliest alert of the group started firing or, for
+// resolved groups, when the last one resolved
+func occurredAt(webhook AlertmanagerWebhook) time.Time {
+	var occurred time.Time
+	for _, alert := range webhook.Alerts {
+		if webhook.Status == "resolved" {
+			if alert.EndsAt.After(occurred) {
+				occurred = alert.EndsAt
+			}
+		} else if !alert.StartsAt.IsZero() && (occurred.IsZero() || alert.StartsAt.Before(occurred)) {
+			occurred = alert.StartsAt
+		}
+	}
+	return occurred
+}
+
+func renderAlertmanagerTemplate(t *template.Template, webhook AlertmanagerWebhook) (string, error) {
+	var buffer bytes.Buffer
+	if err := t.Execute(&buffer, webhook); err != nil {
+		return "", fmt.Errorf("failed to render alertmanager %s template: %s", t.Name(), err)
+	}
+	return strings.TrimSpace(buffer.String()), nil
+}
+
+// AlertmanagerHandler accepts Alertmanager webhook payloads and sends each
+// group as one alert
+func (s *Server) AlertmanagerHandler(mapper *AlertmanagerMapper) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			writeJSON(w, http.StatusMethodNotAllowed, alertResponse{Error: "method not allowed"})
+			return
+		}
+
+		var webhook AlertmanagerWebhook
+		if err := json.NewDecoder(req.Body).Decode(&webhook); err != nil {
+			writeJSON(w, http.StatusBadRequest, alertResponse{Error: "webhook not parseable: " + err.Error()})
+			return
+		}
+		if len(webhook.Alerts) == 0 {
+			writeJSON(w, http.StatusBadRequest, alertResponse{Error: "webhook contains no alerts"})
+			return
+		}
+
+		alert, err := mapper.Map(webhook)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, alertResponse{Error: err.Error()})
+			return
+		}
+
+		s.dispatch(w, alert)
+	})
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AlertmanagerMapper", func() {
+	var (
+		webhook  AlertmanagerWebhook
+		startsAt = time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC)
+	)
+
+	BeforeEach(func() {
+		webhook = AlertmanagerWebhook{
+			Status:       "firing",
+			GroupLabels:  map[string]string{"alertname": "RedisDown"},
+			CommonLabels: map[string]string{"alertname": "RedisDown", "product": "redis", "severity": "warning"},
+			Alerts: []AlertmanagerAlert{
+				{
+					Status:       "firing",
+					Labels:       map[string]string{"alertname": "RedisDown", "instance": "10.0.0.1"},
+					Annotations:  map[string]string{"summary": "Redis down on 10.0.0.1", "description": "No response for 5m"},
+					StartsAt:     startsAt,
+					GeneratorURL: "http://prometheus/graph",
+				},
+				{
+					Status:      "firing",
+					Labels:      map[string]string{"alertname": "RedisDown", "instance": "10.0.0.2"},
+					Annotations: map[string]string{"summary": "Redis down on 10.0.0.2"},
+					StartsAt:    startsAt,
+				},
+			},
+		}
+	})
+
+	It("maps a group of alerts to a single alert with the default templates", func() {
+		mapper, err := NewAlertmanagerMapper(Alertmanager{})
+		Expect(err).NotTo(HaveOccurred())
+
+		alert, err := mapper.Map(webhook)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(alert.Product).To(Equal("redis"))
+		Expect(alert.ServiceInstanceID).To(BeEmpty())
+		Expect(alert.Subject).To(Equal("RedisDown"))
+		Expect(alert.Severity).To(Equal(SeverityWarning))
+		Expect(alert.Resolved).To(BeFalse())
+		Expect(alert.Labels).To(Equal(webhook.CommonLabels))
+		Expect(alert.Content).To(Equal(`[firing] Redis down on 10.0.0.1
+No response for 5m
+Labels: alertname=RedisDown, instance=10.0.0.1
+Started at: 2016-10-01T12:00:00Z
+Source: http://prometheus/graph
+
+[firing] Redis down on 10.0.0.2
+Labels: alertname=RedisDown, instance=10.0.0.2
+Started at: 2016-10-01T12:00:00Z`))
+	})
+
+	It("uses the configured templates", func() {
+		mapper, err := NewAlertmanagerMapper(Alertmanager{
+			Product:         "{{.CommonLabels.alertname}}",
+			ServiceInstance: "{{.GroupLabels.alertname}}-instance",
+			Subject:         "{{len .Alerts}} instances down",
+			Content:         "see {{.ExternalURL}}",
+			Severity:        "critical",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		webhook.ExternalURL = "http://alertmanager"
+
+		alert, err := mapper.Map(webhook)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(alert.Product).To(Equal("RedisDown"))
+		Expect(alert.ServiceInstanceID).To(Equal("RedisDown-instance"))
+		Expect(alert.Subject).To(Equal("2 instances down"))
+		Expect(alert.Content).To(Equal("see http://alertmanager"))
+		Expect(alert.Severity).To(Equal(SeverityCritical))
+	})
+
+	It("maps resolved groups to resolved alerts", func() {
+		webhook.Status = "resolved"
+		mapper, err := NewAlertmanagerMapper(Alertmanager{})
+		Expect(err).NotTo(HaveOccurred())
+
+		alert, err := mapper.Map(webhook)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(alert.Resolved).To(BeTrue())
+	})
+
+	It("treats unknown severities as critical", func() {
+		webhook.CommonLabels["severity"] = "page"
+		mapper, err := NewAlertmanagerMapper(Alertmanager{})
+		Expect(err).NotTo(HaveOccurred())
+
+		alert, err := mapper.Map(webhook)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(alert.Severity).To(Equal(SeverityCritical))
+	})
+
+	It("falls back to a default product", func() {
+		delete(webhook.CommonLabels, "product")
+		mapper, err := NewAlertmanagerMapper(Alertmanager{})
+		Expect(err).NotTo(HaveOccurred())
+
+		alert, err := mapper.Map(webhook)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(alert.Product).To(Equal("alertmanager"))
+	})
+
+	It("rejects invalid templates", func() {
+		_, err := NewAlertmanagerMapper(Alertmanager{Subject: "{{.CommonLabels"})
+		Expect(err).To(MatchError(ContainSubstring("invalid alertmanager config")))
+	})
+})
@@ -0,0 +1,92 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fitBudget renders the notification with as much content as fits in
+// notifications.max_request_bytes, as the notifications service rejects larger
+// requests and rejections are not retried. render is given the maximum number
+// of content bytes to include. Content can take up more than its own size in
+// the request, for example once in the text and once escaped in the HTML body,
+// so the limit is found by bisection.
+func (c *ServiceAlertsClient) fitBudget(contentBytes int, render func(maxContentBytes int) (SpaceNotificationRequest, error)) (SpaceNotificationRequest, error) {
+	budget := c.config.Notifications.MaxRequestBytes
+	fits := func(limit int) (SpaceNotificationRequest, bool, error) {
+		request, err := render(limit)
+		if err != nil {
+			return request, false, err
+		}
+		size, err := requestSize(request)
+		return request, budget <= 0 || size <= budget, err
+	}
+
+	request, ok, err := fits(contentBytes)
+	if ok || err != nil {
+		return request, err
+	}
+
+	fitted, ok, err := fits(0)
+	if err != nil {
+		return SpaceNotificationRequest{}, err
+	}
+	if !ok {
+		size, _ := requestSize(fitted)
+		return SpaceNotificationRequest{}, fmt.Errorf("notification is %d bytes even without content, more than notifications.max_request_bytes of %d", size, budget)
+	}
+
+	for fitting, tooLarge := 0, contentBytes; tooLarge-fitting > 1; {
+		limit := (fitting + tooLarge) / 2
+		request, ok, err := fits(limit)
+		if err != nil {
+			return SpaceNotificationRequest{}, err
+		}
+		if ok {
+			fitting, fitted = limit, request
+		} else {
+			tooLarge = limit
+		}
+	}
+	return fitted, nil
+}
+
+// fullContentReference writes the content of an alert that does not fit in
+// the budget to full_content_dir and returns a note saying where it is, or
+// nothing when no directory is configured or writing failed
+func (c *ServiceAlertsClient) fullContentReference(alert Alert) string {
+	if c.config.FullContentDir == "" {
+		return ""
+	}
+
+	path := filepath.Join(c.config.FullContentDir, fmt.Sprintf("%s-%s.txt", alert.Fingerprint(), time.Now().UTC().Format("20060102T150405Z")))
+	if err := os.MkdirAll(c.config.FullContentDir, 0700); err != nil {
+		c.logger.Printf("Failed to save full content of alert for %s: %s", alert.Product, err)
+		return ""
+	}
+	if err := ioutil.WriteFile(path, []byte(alert.Content), 0600); err != nil {
+		c.logger.Printf("Failed to save full content of alert for %s: %s", alert.Product, err)
+		return ""
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "the alerting host"
+	}
+	return fmt.Sprintf("\n\n[The full content is saved in %s on %s]", path, hostname)
+}
+
+func requestSize(request SpaceNotificationRequest) (int, error) {
+	body, err := json.Marshal(request)
+	return len(body), err
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("request size budget", func() {
+	var (
+		output  *bytes.Buffer
+		config  Config
+		dir     string
+		content string
+	)
+
+	send := func(alert Alert) (SpaceNotificationRequest, error) {
+		output.Reset()
+		err := New(config, log.New(GinkgoWriter, "", 0)).WithDryRunOutput(output).SendAlert(alert)
+		if err != nil {
+			return SpaceNotificationRequest{}, err
+		}
+		var notification DryRunNotification
+		Expect(json.Unmarshal(output.Bytes(), &notification)).To(Succeed())
+		return notification.Request, nil
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "budget")
+		Expect(err).NotTo(HaveOccurred())
+
+		output = &bytes.Buffer{}
+		config = Config{
+			Notifications: Notifications{CFOrg: "org", CFSpace: "space", MaxRequestBytes: 2000},
+			DryRun:        true,
+		}
+		var lines []string
+		for i := 0; i < 1000; i++ {
+			lines = append(lines, "log line <with markup> that needs escaping")
+		}
+		content = "first line\n" + strings.Join(lines, "\n") + "\nlast line"
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("leaves notifications within the budget alone", func() {
+		request, err := send(Alert{Product: "redis", Subject: "down", Content: "short"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(request.Text).To(ContainSubstring("\n\nshort\n\n"))
+	})
+
+	It("keeps the start and end of the content of larger notifications", func() {
+		config.Templates.HTML.Enabled = true
+		request, err := send(Alert{Product: "redis", Subject: "down", Content: content})
+		Expect(err).NotTo(HaveOccurred())
+
+		body, err := json.Marshal(request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(body)).To(BeNumerically("<=", 2000))
+		Expect(request.Text).To(ContainSubstring("first line"))
+		Expect(request.Text).To(ContainSubstring("bytes omitted ...]"))
+		Expect(request.Text).To(ContainSubstring("last line"))
+		Expect(request.HTML).To(ContainSubstring("last line"))
+	})
+
+	It("saves the full content and refers to it when a directory is configured", func() {
+		config.FullContentDir = filepath.Join(dir, "full")
+		request, err := send(Alert{Product: "redis", Subject: "down", Content: content})
+		Expect(err).NotTo(HaveOccurred())
+
+		reference := regexp.MustCompile(`\[The full content is saved in (\S+) on `).FindStringSubmatch(request.Text)
+		Expect(reference).NotTo(BeNil())
+		saved, err := ioutil.ReadFile(reference[1])
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(saved)).To(Equal(content))
+	})
+
+	It("shortens digests too", func() {
+		alerts := []Alert{{Product: "redis", Subject: "one", Content: content}, {Product: "redis", Subject: "two", Content: content}}
+		Expect(New(config, log.New(GinkgoWriter, "", 0)).WithDryRunOutput(output).SendDigestTo(Destination{CFOrg: "org", CFSpace: "space"}, alerts)).To(Succeed())
+
+		var notification DryRunNotification
+		Expect(json.Unmarshal(output.Bytes(), &notification)).To(Succeed())
+		body, err := json.Marshal(notification.Request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(body)).To(BeNumerically("<=", 2000))
+		Expect(notification.Request.Text).To(HaveSuffix("]"))
+	})
+
+	It("fails when the notification does not fit even without content", func() {
+		config.Notifications.MaxRequestBytes = 50
+		_, err := send(Alert{Product: "redis", Subject: "down", Content: content})
+		Expect(err).To(MatchError(ContainSubstring("even without content, more than notifications.max_request_bytes of 50")))
+	})
+})
@@ -6,11 +6,29 @@
 
 package client
 
+import "time"
+
 type Config struct {
-	CloudController      CloudController `yaml:"cloud_controller"`
-	Notifications        Notifications   `yaml:"notifications"`
-	GlobalTimeoutSeconds int             `yaml:"timeout_seconds"`
-	SkipSSLValidation    *bool           `yaml:"skip_ssl_validation"`
+	CloudController      CloudController    `yaml:"cloud_controller"`
+	Notifications        Notifications      `yaml:"notifications"`
+	GlobalTimeoutSeconds int                `yaml:"timeout_seconds"`
+	SkipSSLValidation    *bool              `yaml:"skip_ssl_validation"`
+	Digest               Digest             `yaml:"digest"`
+	Receivers            []Receiver         `yaml:"receivers"`
+	Route                Route              `yaml:"route"`
+	SilencesFile         string             `yaml:"silences_file"`
+	EscalationPolicies   []EscalationPolicy `yaml:"escalation_policies"`
+	Acknowledgement      Acknowledgement    `yaml:"acknowledgement"`
+	StateFile            string             `yaml:"state_file"`
+	Serve                Serve              `yaml:"serve"`
+	Alertmanager         Alertmanager       `yaml:"alertmanager"`
+	MaxContentBytes      int                `yaml:"max_content_bytes"`
+	Heartbeats           Heartbeats         `yaml:"heartbeats"`
+	Watch                Watch              `yaml:"watch"`
+	DryRun               bool               `yaml:"dry_run"`
+	DryRunResolveGUIDs   bool               `yaml:"dry_run_resolve_guids"`
+	Templates            Templates          `yaml:"templates"`
+	FullContentDir       string             `yaml:"full_content_dir"`
 }
 
 type CloudController struct {
@@ -20,10 +38,141 @@ type CloudController struct {
 }
 
 type Notifications struct {
-	ServiceURL   string `yaml:"service_url"`
-	CFOrg        string `yaml:"cf_org"`
-	CFSpace      string `yaml:"cf_space"`
-	ReplyTo      string `yaml:"reply_to"`
-	ClientID     string `yaml:"client_id"`
-	ClientSecret string `yaml:"client_secret"`
+	ServiceURL       string           `yaml:"service_url"`
+	CFOrg            string           `yaml:"cf_org"`
+	CFSpace          string           `yaml:"cf_space"`
+	ReplyTo          string           `yaml:"reply_to"`
+	ClientID         string           `yaml:"client_id"`
+	ClientSecret     string           `yaml:"client_secret"`
+	Locale           string           `yaml:"locale"`
+	MaxRequestBytes  int              `yaml:"max_request_bytes"`
+	ZeroRecipients   string           `yaml:"zero_recipients"`
+	DeliveryTracking DeliveryTracking `yaml:"delivery_tracking"`
+}
+
+type DeliveryTracking struct {
+	Enabled             bool `yaml:"enabled"`
+	TimeoutSeconds      int  `yaml:"timeout_seconds"`
+	PollIntervalSeconds int  `yaml:"poll_interval_seconds"`
+}
+
+type Digest struct {
+	Severity        string `yaml:"severity"`
+	IntervalSeconds int    `yaml:"interval_seconds"`
+}
+
+type Receiver struct {
+	Name        string `yaml:"name"`
+	Destination `yaml:",inline"`
+	Locale      string `yaml:"locale"`
+}
+
+type Route struct {
+	Match     RouteMatch `yaml:"match"`
+	Receivers []string   `yaml:"receivers"`
+	Continue  bool       `yaml:"continue"`
+	Routes    []Route    `yaml:"routes"`
+}
+
+type RouteMatch struct {
+	Product         string            `yaml:"product"`
+	ServiceInstance string            `yaml:"service_instance"`
+	Severity        string            `yaml:"severity"`
+	Labels          map[string]string `yaml:"labels"`
+}
+
+type EscalationPolicy struct {
+	Name  string           `yaml:"name"`
+	Match RouteMatch       `yaml:"match"`
+	Steps []EscalationStep `yaml:"steps"`
+}
+
+type EscalationStep struct {
+	Receiver     string `yaml:"receiver"`
+	DelaySeconds int    `yaml:"delay_seconds"`
+}
+
+type Acknowledgement struct {
+	URL        string `yaml:"url"`
+	Secret     string `yaml:"secret"`
+	TTLSeconds int    `yaml:"ttl_seconds"`
+}
+
+// SendTimeout is how long sending an alert may take, including retries:
+// timeout_seconds, or 60 seconds when it is not set
+func (c Config) SendTimeout() time.Duration {
+	if c.GlobalTimeoutSeconds != 0 {
+		return time.Duration(c.GlobalTimeoutSeconds) * time.Second
+	}
+	return defaultGlobalTimeout
+}
+
+type Serve struct {
+	Listen                 string   `yaml:"listen"`
+	BearerTokens           []string `yaml:"bearer_tokens"`
+	TLS                    ServeTLS `yaml:"tls"`
+	ShutdownTimeoutSeconds int      `yaml:"shutdown_timeout_seconds"`
+}
+
+type Heartbeats struct {
+	File                 string `yaml:"file"`
+	CheckIntervalSeconds int    `yaml:"check_interval_seconds"`
+}
+
+type Watch struct {
+	Files               []string    `yaml:"files"`
+	PollIntervalSeconds int         `yaml:"poll_interval_seconds"`
+	Rules               []WatchRule `yaml:"rules"`
+}
+
+type WatchRule struct {
+	Name            string `yaml:"name"`
+	Pattern         string `yaml:"pattern"`
+	Product         string `yaml:"product"`
+	ServiceInstance string `yaml:"service_instance"`
+	Subject         string `yaml:"subject"`
+	Severity        string `yaml:"severity"`
+	ThrottleSeconds int    `yaml:"throttle_seconds"`
+	ContextBefore   int    `yaml:"context_before"`
+	ContextAfter    int    `yaml:"context_after"`
+}
+
+type Templates struct {
+	Subject         string             `yaml:"subject"`
+	SubjectFile     string             `yaml:"subject_file"`
+	Body            string             `yaml:"body"`
+	BodyFile        string             `yaml:"body_file"`
+	Overrides       []TemplateOverride `yaml:"overrides"`
+	HTML            EmailHTML          `yaml:"html"`
+	Timezone        string             `yaml:"timezone"`
+	TimestampLayout string             `yaml:"timestamp_layout"`
+}
+
+type EmailHTML struct {
+	Enabled    bool   `yaml:"enabled"`
+	BrandName  string `yaml:"brand_name"`
+	BrandColor string `yaml:"brand_color"`
+}
+
+type TemplateOverride struct {
+	Product     string `yaml:"product"`
+	Severity    string `yaml:"severity"`
+	Subject     string `yaml:"subject"`
+	SubjectFile string `yaml:"subject_file"`
+	Body        string `yaml:"body"`
+	BodyFile    string `yaml:"body_file"`
+}
+
+type ServeTLS struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+}
+
+type Alertmanager struct {
+	Product         string `yaml:"product"`
+	ServiceInstance string `yaml:"service_instance"`
+	Subject         string `yaml:"subject"`
+	Content         string `yaml:"content"`
+	Severity        string `yaml:"severity"`
 }
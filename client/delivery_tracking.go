@@ -0,0 +1,195 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+const (
+	DeliveryStatusDelivered     = "delivered"
+	DeliveryStatusFailed        = "failed"
+	DeliveryStatusUndeliverable = "undeliverable"
+
+	defaultDeliveryTrackingTimeout      = 5 * time.Minute
+	defaultDeliveryTrackingPollInterval = 10 * time.Second
+)
+
+type DeliveryStatusChecker interface {
+	DeliveryStatus(notificationID string) (string, error)
+}
+
+// DeliveryFailure is a notification the notifications service accepted but
+// could not email to a recipient
+type DeliveryFailure struct {
+	Destination Destination
+	Recipient   RecipientReceipt
+}
+
+func (f DeliveryFailure) String() string {
+	return fmt.Sprintf("notification %s to %s in %s has status %s", f.Recipient.NotificationID, f.Recipient.Email, f.Destination, f.Recipient.Status)
+}
+
+// DeliveryTracker follows up on notifications the notifications service
+// accepted, as it emails recipients after responding. It polls the status of
+// each notification until it is delivered or has failed, or until the
+// deadline.
+type DeliveryTracker struct {
+	checker      DeliveryStatusChecker
+	timeout      time.Duration
+	pollInterval time.Duration
+	clock        clock.Clock
+	logger       *log.Logger
+	onFailure    func(DeliveryFailure)
+}
+
+func NewDeliveryTracker(config DeliveryTracking, checker DeliveryStatusChecker, clock clock.Clock, logger *log.Logger) (*DeliveryTracker, error) {
+	if config.TimeoutSeconds < 0 || config.PollIntervalSeconds < 0 {
+		return nil, ConfigError{Section: "delivery_tracking", Err: errors.New("timeout_seconds and poll_interval_seconds must not be negative")}
+	}
+
+	tracker := &DeliveryTracker{
+		checker:      checker,
+		timeout:      defaultDeliveryTrackingTimeout,
+		pollInterval: defaultDeliveryTrackingPollInterval,
+		clock:        clock,
+		logger:       logger,
+		onFailure:    func(DeliveryFailure) {},
+	}
+	if config.TimeoutSeconds > 0 {
+		tracker.timeout = time.Duration(config.TimeoutSeconds) * time.Second
+	}
+	if config.PollIntervalSeconds > 0 {
+		tracker.pollInterval = time.Duration(config.PollIntervalSeconds) * time.Second
+	}
+	return tracker, nil
+}
+
+// WithFailureHandler sets a function called for every failed delivery as soon
+// as it is seen
+func (t *DeliveryTracker) WithFailureHandler(onFailure func(DeliveryFailure)) *DeliveryTracker {
+	t.onFailure = onFailure
+	return t
+}
+
+// Track blocks until every recipient in the receipts has been emailed or
+// failed, or until the deadline, and returns the receipts with their last
+// known status. Statuses that could not be checked are logged and checked
+// again at the next poll.
+func (t *DeliveryTracker) Track(receipts []DeliveryReceipt) []DeliveryReceipt {
+	tracked := make([]DeliveryReceipt, len(receipts))
+	for i, receipt := range receipts {
+		tracked[i] = receipt
+		if receipt.Recipients != nil {
+			tracked[i].Recipients = append([]RecipientReceipt{}, receipt.Recipients...)
+		}
+	}
+
+	deadline := t.clock.Now().Add(t.timeout)
+	for pending := countPending(tracked); pending > 0; pending = countPending(tracked) {
+		if t.clock.Now().Add(t.pollInterval).After(deadline) {
+			t.logger.Printf("Stopped tracking delivery after %s, %d notifications not yet delivered", t.timeout, pending)
+			break
+		}
+		t.clock.Sleep(t.pollInterval)
+		t.poll(tracked)
+	}
+	return tracked
+}
+
+func (t *DeliveryTracker) poll(receipts []DeliveryReceipt) {
+	for _, receipt := range receipts {
+		for i := range receipt.Recipients {
+			recipient := &receipt.Recipients[i]
+			if !deliveryPending(*recipient) {
+				continue
+			}
+
+			status, err := t.checker.DeliveryStatus(recipient.NotificationID)
+			if err != nil {
+				t.logger.Printf("Failed to check delivery of notification %s: %s", recipient.NotificationID, err)
+				continue
+			}
+			recipient.Status = status
+
+			if status == DeliveryStatusFailed || status == DeliveryStatusUndeliverable {
+				failure := DeliveryFailure{Destination: receipt.Destination, Recipient: *recipient}
+				t.logger.Printf("Delivery failed: %s", failure)
+				t.onFailure(failure)
+			}
+		}
+	}
+}
+
+func countPending(receipts []DeliveryReceipt) int {
+	var pending int
+	for _, receipt := range receipts {
+		for _, recipient := range receipt.Recipients {
+			if deliveryPending(recipient) {
+				pending++
+			}
+		}
+	}
+	return pending
+}
+
+func deliveryPending(recipient RecipientReceipt) bool {
+	switch recipient.Status {
+	case DeliveryStatusDelivered, DeliveryStatusFailed, DeliveryStatusUndeliverable:
+		return false
+	default:
+		return recipient.NotificationID != ""
+	}
+}
+
+// DeliveryStatus looks up the status of a notification, such as "queued" or
+// "delivered", in the notifications service. It makes a single attempt, as
+// the DeliveryTracker polls anyway.
+func (c *ServiceAlertsClient) DeliveryStatus(notificationID string) (string, error) {
+	if err := c.setupUaaUrl(); err != nil {
+		return "", err
+	}
+	token, err := c.obtainNotificationsClientToken()
+	if err != nil {
+		return "", err
+	}
+
+	statusURL, err := joinURL(c.config.Notifications.ServiceURL, fmt.Sprintf("/messages/%s", notificationID), "")
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("GET", statusURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-NOTIFICATIONS-VERSION", "1")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	response, err := c.httpClient.httpClient.Do(req)
+	if err != nil {
+		return "", UnreachableError{Label: "CF Notifications", Err: err}
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("CF Notifications expected to return HTTP 200, got %d. %s", response.StatusCode, c.httpClient.responseBodyDetails(response))
+	}
+
+	defer response.Body.Close()
+	var message struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&message); err != nil {
+		return "", fmt.Errorf("CF Notifications response not parseable: %s", err)
+	}
+	return message.Status, nil
+}
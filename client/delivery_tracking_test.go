@@ -0,0 +1,142 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeStatusChecker struct {
+	mutex    sync.Mutex
+	statuses map[string][]string
+	checked  []string
+}
+
+// DeliveryStatus returns the next status queued for the notification, and
+// keeps returning the last one
+func (f *fakeStatusChecker) DeliveryStatus(notificationID string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.checked = append(f.checked, notificationID)
+	statuses := f.statuses[notificationID]
+	if len(statuses) == 0 {
+		return "", errors.New("unknown notification")
+	}
+	if len(statuses) > 1 {
+		f.statuses[notificationID] = statuses[1:]
+	}
+	return statuses[0], nil
+}
+
+var _ = Describe("DeliveryTracker", func() {
+	var (
+		clock    *fakeClock
+		checker  *fakeStatusChecker
+		failures []DeliveryFailure
+		tracker  *DeliveryTracker
+		receipts []DeliveryReceipt
+	)
+
+	destination := Destination{CFOrg: "org", CFSpace: "space"}
+
+	BeforeEach(func() {
+		clock = newFakeClock(time.Date(2016, 7, 1, 12, 0, 0, 0, time.UTC))
+		checker = &fakeStatusChecker{statuses: map[string][]string{}}
+		failures = nil
+
+		var err error
+		tracker, err = NewDeliveryTracker(DeliveryTracking{TimeoutSeconds: 60, PollIntervalSeconds: 10}, checker, clock, log.New(GinkgoWriter, "", 0))
+		Expect(err).NotTo(HaveOccurred())
+		tracker.WithFailureHandler(func(failure DeliveryFailure) {
+			failures = append(failures, failure)
+		})
+
+		receipts = []DeliveryReceipt{{Destination: destination, Recipients: []RecipientReceipt{
+			{Recipient: "user-1", Email: "one@example.com", NotificationID: "n-1", Status: "queued"},
+			{Recipient: "user-2", Email: "two@example.com", NotificationID: "n-2", Status: "queued"},
+		}}}
+	})
+
+	track := func() <-chan []DeliveryReceipt {
+		done := make(chan []DeliveryReceipt, 1)
+		go func() {
+			done <- tracker.Track(receipts)
+		}()
+		return done
+	}
+
+	poll := func() {
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(10 * time.Second)
+	}
+
+	It("polls until every notification is delivered or has failed", func() {
+		checker.statuses["n-1"] = []string{"queued", "delivered"}
+		checker.statuses["n-2"] = []string{"failed"}
+
+		done := track()
+		poll()
+		poll()
+
+		var tracked []DeliveryReceipt
+		Eventually(done).Should(Receive(&tracked))
+		Expect(tracked[0].Recipients[0].Status).To(Equal("delivered"))
+		Expect(tracked[0].Recipients[1].Status).To(Equal("failed"))
+		Expect(checker.checked).To(Equal([]string{"n-1", "n-2", "n-1"}))
+		Expect(receipts[0].Recipients[0].Status).To(Equal("queued"), "the receipts passed in should not change")
+
+		Expect(failures).To(HaveLen(1))
+		Expect(failures[0].Destination).To(Equal(destination))
+		Expect(failures[0].Recipient.Email).To(Equal("two@example.com"))
+	})
+
+	It("stops at the deadline with the last known status", func() {
+		checker.statuses["n-1"] = []string{"queued"}
+		checker.statuses["n-2"] = []string{"delivered"}
+
+		done := track()
+		for i := 0; i < 6; i++ {
+			poll()
+		}
+
+		var tracked []DeliveryReceipt
+		Eventually(done).Should(Receive(&tracked))
+		Expect(tracked[0].Recipients[0].Status).To(Equal("queued"))
+		Expect(checker.checked).To(HaveLen(7))
+		Expect(failures).To(BeEmpty())
+	})
+
+	It("skips receipts without recipients", func() {
+		checker.statuses["n-2"] = []string{"delivered"}
+		receipts[0].Recipients = receipts[0].Recipients[1:]
+		receipts = append(receipts, DeliveryReceipt{Destination: destination})
+
+		done := track()
+		poll()
+
+		var tracked []DeliveryReceipt
+		Eventually(done).Should(Receive(&tracked))
+		Expect(tracked[0].Recipients[0].Status).To(Equal("delivered"))
+		Expect(tracked[1].Recipients).To(BeNil())
+	})
+
+	It("returns straight away when there is nothing to track", func() {
+		Expect(tracker.Track([]DeliveryReceipt{{Destination: destination}})).To(Equal([]DeliveryReceipt{{Destination: destination}}))
+	})
+
+	It("rejects negative durations", func() {
+		_, err := NewDeliveryTracker(DeliveryTracking{PollIntervalSeconds: -1}, checker, clock, log.New(GinkgoWriter, "", 0))
+		Expect(err).To(MatchError("invalid delivery_tracking config: timeout_seconds and poll_interval_seconds must not be negative"))
+	})
+})
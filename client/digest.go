@@ -0,0 +1,167 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+const defaultDigestInterval = time.Hour
+
+type DigestSender interface {
+	SendDigestTo(destination Destination, alerts []Alert) error
+}
+
+// DigestAggregator collects alerts below the configured severity and sends
+// them as one notification per destination at the end of every interval
+type DigestAggregator struct {
+	sender    DigestSender
+	enabled   bool
+	threshold Severity
+	interval  time.Duration
+	clock     clock.Clock
+	logger    *log.Logger
+	metrics   Metrics
+
+	mutex        sync.Mutex
+	pending      map[Destination][]Alert
+	destinations []Destination
+}
+
+func NewDigestAggregator(config Digest, sender DigestSender, clock clock.Clock, logger *log.Logger) (*DigestAggregator, error) {
+	aggregator := &DigestAggregator{
+		sender:   sender,
+		interval: defaultDigestInterval,
+		clock:    clock,
+		logger:   logger,
+		metrics:  noopMetrics{},
+		pending:  map[Destination][]Alert{},
+	}
+
+	if config.Severity != "" {
+		threshold, err := ParseSeverity(config.Severity)
+		if err != nil {
+			return nil, ConfigError{Section: "digest", Err: err}
+		}
+		aggregator.enabled = true
+		aggregator.threshold = threshold
+	}
+
+	if config.IntervalSeconds < 0 {
+		return nil, ConfigError{Section: "digest", Err: fmt.Errorf("interval_seconds must not be negative")}
+	}
+	if config.IntervalSeconds != 0 {
+		aggregator.interval = time.Duration(config.IntervalSeconds) * time.Second
+	}
+
+	return aggregator, nil
+}
+
+// WithMetrics records the number of alerts waiting for the next digest
+func (d *DigestAggregator) WithMetrics(metrics Metrics) *DigestAggregator {
+	d.metrics = metrics
+	return d
+}
+
+// Collect holds on to the alert until the next flush when its severity is
+// below the digest threshold. It returns false when the alert should be sent
+// immediately instead.
+func (d *DigestAggregator) Collect(destination Destination, alert Alert) bool {
+	if !d.enabled || alert.Severity >= d.threshold {
+		return false
+	}
+
+	if alert.OccurredAt.IsZero() {
+		alert.OccurredAt = d.clock.Now()
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.pending[destination]; !ok {
+		d.destinations = append(d.destinations, destination)
+	}
+	d.pending[destination] = append(d.pending[destination], alert)
+	d.recordPending()
+	return true
+}
+
+func (d *DigestAggregator) Pending() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.pendingCount()
+}
+
+func (d *DigestAggregator) pendingCount() int {
+	count := 0
+	for _, alerts := range d.pending {
+		count += len(alerts)
+	}
+	return count
+}
+
+// Flush sends a digest to every destination with pending alerts. Alerts that
+// fail to send are kept for the next flush.
+func (d *DigestAggregator) Flush() error {
+	d.mutex.Lock()
+	pending, destinations := d.pending, d.destinations
+	d.pending, d.destinations = map[Destination][]Alert{}, nil
+	d.mutex.Unlock()
+
+	var lastErr error
+	for _, destination := range destinations {
+		alerts := pending[destination]
+		if err := d.sender.SendDigestTo(destination, alerts); err != nil {
+			d.logger.Printf("Failed to send digest of %d alerts to %s: %s", len(alerts), destination, err)
+			d.requeue(destination, alerts)
+			lastErr = err
+		}
+	}
+
+	d.mutex.Lock()
+	d.recordPending()
+	d.mutex.Unlock()
+	return lastErr
+}
+
+// Run flushes the digest every interval until stop is closed, then flushes
+// whatever is still pending
+func (d *DigestAggregator) Run(stop <-chan struct{}) {
+	ticker := d.clock.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			d.Flush()
+		case <-stop:
+			d.Flush()
+			return
+		}
+	}
+}
+
+func (d *DigestAggregator) requeue(destination Destination, alerts []Alert) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.pending[destination]; !ok {
+		d.destinations = append(d.destinations, destination)
+	}
+	d.pending[destination] = append(alerts, d.pending[destination]...)
+}
+
+// recordPending sets the digest queue depth. Alerts being flushed are no
+// longer counted. Callers must hold the mutex.
+func (d *DigestAggregator) recordPending() {
+	d.metrics.SetGauge(MetricQueueDepth, Labels{"queue": "digest"}, float64(d.pendingCount()))
+}
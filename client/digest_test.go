@@ -0,0 +1,116 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type sentDigest struct {
+	destination Destination
+	alerts      []Alert
+}
+
+type fakeDigestSender struct {
+	sent []sentDigest
+	err  error
+}
+
+func (f *fakeDigestSender) SendDigestTo(destination Destination, alerts []Alert) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, sentDigest{destination: destination, alerts: alerts})
+	return nil
+}
+
+var _ = Describe("DigestAggregator", func() {
+	var (
+		sender     *fakeDigestSender
+		aggregator *DigestAggregator
+		opsSpace   = Destination{CFOrg: "org", CFSpace: "ops"}
+		devSpace   = Destination{CFOrg: "org", CFSpace: "dev"}
+	)
+
+	BeforeEach(func() {
+		sender = &fakeDigestSender{}
+		var err error
+		aggregator, err = NewDigestAggregator(Digest{Severity: "warning"}, sender, clock.NewClock(), log.New(GinkgoWriter, "", 0))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("collects alerts below the configured severity", func() {
+		Expect(aggregator.Collect(opsSpace, Alert{Severity: SeverityInfo})).To(BeTrue())
+		Expect(aggregator.Collect(opsSpace, Alert{Severity: SeverityWarning})).To(BeFalse())
+		Expect(aggregator.Collect(opsSpace, Alert{Severity: SeverityCritical})).To(BeFalse())
+		Expect(aggregator.Pending()).To(Equal(1))
+	})
+
+	It("sends one digest per destination when flushed", func() {
+		at := time.Date(2016, 10, 1, 0, 0, 0, 0, time.UTC)
+		aggregator.Collect(opsSpace, Alert{Subject: "a", OccurredAt: at})
+		aggregator.Collect(devSpace, Alert{Subject: "b", OccurredAt: at})
+		aggregator.Collect(opsSpace, Alert{Subject: "c", OccurredAt: at})
+
+		Expect(aggregator.Flush()).To(Succeed())
+
+		Expect(sender.sent).To(Equal([]sentDigest{
+			{destination: opsSpace, alerts: []Alert{{Subject: "a", OccurredAt: at}, {Subject: "c", OccurredAt: at}}},
+			{destination: devSpace, alerts: []Alert{{Subject: "b", OccurredAt: at}}},
+		}))
+		Expect(aggregator.Pending()).To(BeZero())
+	})
+
+	It("records when alerts without an occurrence time were collected", func() {
+		collectedAt := time.Date(2016, 10, 1, 0, 0, 0, 0, time.UTC)
+		aggregator, err := NewDigestAggregator(Digest{Severity: "warning"}, sender, newFakeClock(collectedAt), log.New(GinkgoWriter, "", 0))
+		Expect(err).NotTo(HaveOccurred())
+
+		aggregator.Collect(opsSpace, Alert{Subject: "a"})
+		Expect(aggregator.Flush()).To(Succeed())
+		Expect(sender.sent[0].alerts[0].OccurredAt).To(Equal(collectedAt))
+	})
+
+	It("keeps alerts that failed to send for the next flush", func() {
+		aggregator.Collect(opsSpace, Alert{Subject: "a"})
+		sender.err = errors.New("notifications unavailable")
+
+		Expect(aggregator.Flush()).To(MatchError("notifications unavailable"))
+		Expect(aggregator.Pending()).To(Equal(1))
+	})
+
+	It("collects nothing when no severity is configured", func() {
+		disabled, err := NewDigestAggregator(Digest{}, sender, clock.NewClock(), log.New(GinkgoWriter, "", 0))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(disabled.Collect(opsSpace, Alert{Severity: SeverityInfo})).To(BeFalse())
+	})
+
+	It("rejects an unknown severity", func() {
+		_, err := NewDigestAggregator(Digest{Severity: "urgent"}, sender, clock.NewClock(), log.New(GinkgoWriter, "", 0))
+		Expect(err).To(MatchError("invalid digest config: unknown severity: 'urgent'"))
+	})
+
+	It("flushes pending alerts when stopped", func() {
+		aggregator.Collect(opsSpace, Alert{Subject: "a"})
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			aggregator.Run(stop)
+			close(done)
+		}()
+
+		close(stop)
+		Eventually(done, time.Second).Should(BeClosed())
+		Expect(sender.sent).To(HaveLen(1))
+	})
+})
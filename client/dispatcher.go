@@ -0,0 +1,165 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import "time"
+
+// Dispatcher takes an alert through silences and routing, then hands it to the
+// digest or sends it to each receiver, and finally starts or stops escalation
+type Dispatcher struct {
+	client    *ServiceAlertsClient
+	router    *Router
+	digest    *DigestAggregator
+	escalator *Escalator
+}
+
+func NewDispatcher(client *ServiceAlertsClient) (*Dispatcher, error) {
+	router, err := NewRouter(client.config)
+	if err != nil {
+		return nil, err
+	}
+	return &Dispatcher{client: client, router: router}, nil
+}
+
+func (d *Dispatcher) WithDigest(digest *DigestAggregator) *Dispatcher {
+	d.digest = digest
+	return d
+}
+
+func (d *Dispatcher) WithEscalator(escalator *Escalator) *Dispatcher {
+	d.escalator = escalator
+	return d
+}
+
+func (d *Dispatcher) Dispatch(alert Alert) error {
+	_, err := d.DispatchWithReceipts(alert)
+	return err
+}
+
+// DispatchWithReceipts is Dispatch, also returning the receipt of every space
+// the alert was sent to. Alerts that are silenced or collected into a digest
+// have no receipt yet. Alerts without an occurrence time are given one only
+// when they are deferred by a silence, collected into a digest or escalated,
+// so that they still say when they first came in.
+//
+// When the alert is routed to several receivers and only some of them fail,
+// the error is a ReceiverErrors and the receipts of the others are returned.
+func (d *Dispatcher) DispatchWithReceipts(alert Alert) ([]DeliveryReceipt, error) {
+	return d.dispatch(alert, nil)
+}
+
+// dispatch sends the alert to the receivers it is routed to, or only to the
+// named ones when any are given
+func (d *Dispatcher) dispatch(alert Alert, only []string) ([]DeliveryReceipt, error) {
+	if silenced, err := d.client.silence(alert, only); silenced || err != nil {
+		return nil, err
+	}
+
+	receivers := d.router.Route(alert)
+	if len(only) > 0 {
+		receivers = filterReceivers(receivers, only)
+	}
+
+	// a dry run must not change escalation state, which outlives it
+	escalator := d.escalator
+	if d.client.config.DryRun {
+		escalator = nil
+	}
+
+	if escalator != nil && alert.Resolved {
+		escalator.Resolve(alert.Fingerprint())
+	}
+
+	receipts, err := d.sendToReceivers(alert, receivers)
+
+	// escalate as long as any receiver was sent the alert, but not alerts
+	// that were only collected into a digest
+	if escalator != nil && !alert.Resolved && len(receipts) > 0 {
+		escalator.Start(alert)
+	}
+	return receipts, err
+}
+
+// SendDeferred dispatches alerts deferred by silences that have since ended.
+// Alerts that fail to send are deferred again, for only the receivers that
+// failed, and are retried the next time.
+func (d *Dispatcher) SendDeferred() error {
+	store := d.client.silences
+	if store == nil {
+		return nil
+	}
+
+	released, err := store.TakeDeferred(time.Now())
+	if err != nil {
+		return err
+	}
+
+	var sendErr error
+	for _, deferred := range released {
+		_, err := d.dispatch(deferred.Alert, deferred.Receivers)
+		if err == nil {
+			continue
+		}
+
+		sendErr = err
+		if failed, ok := err.(ReceiverErrors); ok {
+			deferred.Receivers = failed.Receivers()
+		}
+		if deferErr := store.keepDeferred(deferred); deferErr != nil {
+			d.client.logger.Printf("Failed to keep deferred alert for %s: %s", deferred.Alert.Product, deferErr)
+		}
+	}
+	return sendErr
+}
+
+// sendToReceivers returns the error itself when the only receiver fails, and
+// a ReceiverErrors when any of several receivers fail
+func (d *Dispatcher) sendToReceivers(alert Alert, receivers []Receiver) ([]DeliveryReceipt, error) {
+	var receipts []DeliveryReceipt
+	failed := ReceiverErrors{}
+	for _, receiver := range receivers {
+		receipt, sent, err := d.send(receiver, alert)
+		if err != nil {
+			if len(receivers) == 1 {
+				return nil, err
+			}
+			d.client.logger.Printf("Failed to send alert to receiver %s: %s", receiver.Name, err)
+			failed[receiver.Name] = err
+			continue
+		}
+		if sent {
+			receipts = append(receipts, receipt)
+		}
+	}
+	if len(failed) > 0 {
+		return receipts, failed
+	}
+	return receipts, nil
+}
+
+func (d *Dispatcher) send(receiver Receiver, alert Alert) (DeliveryReceipt, bool, error) {
+	if d.digest != nil && d.digest.Collect(receiver.Destination, alert) {
+		return DeliveryReceipt{}, false, nil
+	}
+	receipt, err := d.client.SendAlertToWithReceipt(receiver.Destination, alert)
+	return receipt, err == nil, err
+}
+
+func filterReceivers(receivers []Receiver, names []string) []Receiver {
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+
+	var filtered []Receiver
+	for _, receiver := range receivers {
+		if wanted[receiver.Name] {
+			filtered = append(filtered, receiver)
+		}
+	}
+	return filtered
+}
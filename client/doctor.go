@@ -0,0 +1,247 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+type DiagnosticStatus string
+
+const (
+	DiagnosticPass DiagnosticStatus = "pass"
+	DiagnosticFail DiagnosticStatus = "fail"
+	DiagnosticSkip DiagnosticStatus = "skip"
+)
+
+// Diagnostic is the outcome of one step of sending an alert, with a hint on
+// how to fix it when it failed
+type Diagnostic struct {
+	Check  string
+	Status DiagnosticStatus
+	Detail string
+	Hint   string
+}
+
+var (
+	cfUserScopes              = []string{"cloud_controller.read", "cloud_controller.admin", "cloud_controller.admin_read_only"}
+	notificationsClientScopes = []string{"notifications.write"}
+)
+
+// Diagnose checks the config and then every step of sending an alert
+// separately, without sending one. Steps that depend on a failed step are
+// skipped.
+func (c *ServiceAlertsClient) Diagnose() []Diagnostic {
+	d := &diagnosis{}
+
+	d.run("config", func() (string, string, error) {
+		return "", "fix the config file, see the README for its format", c.validateConfig()
+	})
+	d.run("locales", func() (string, string, error) {
+		return "", "use one of the locales listed by send-service-alert locales", c.validateLocales()
+	})
+
+	var uaaURL string
+	d.run("Cloud Controller reachable", func() (string, string, error) {
+		return c.config.CloudController.URL, "check cloud_controller.url and that this VM can reach it; set skip_ssl_validation if it uses a self-signed certificate", c.checkReachable(c.config.CloudController.URL, "/v2/info")
+	}, "config")
+	d.run("Cloud Controller /v2/info", func() (string, string, error) {
+		var err error
+		uaaURL, err = c.getUaaUrl()
+		if err == nil && uaaURL == "" {
+			err = fmt.Errorf("response has no token_endpoint")
+		}
+		return "UAA at " + uaaURL, "cloud_controller.url must be the Cloud Foundry API URL, such as https://api.sys.example.com", err
+	}, "Cloud Controller reachable")
+	if uaaURL != "" {
+		c.mutex.Lock()
+		c.uaaUrl = uaaURL
+		c.mutex.Unlock()
+	}
+
+	var userToken, clientToken UAATokenResponse
+	d.run("UAA password grant", func() (string, string, error) {
+		var err error
+		userToken, err = c.requestUAAToken(c.config.CloudController.User, c.config.CloudController.Password, "password")
+		return "user " + c.config.CloudController.User, "check cloud_controller.user and cloud_controller.password", err
+	}, "Cloud Controller /v2/info")
+	d.run("CF user token scopes", func() (string, string, error) {
+		return userToken.Scope, "the user needs one of the scopes " + strings.Join(cfUserScopes, ", "), requireScope(userToken.Scope, cfUserScopes)
+	}, "UAA password grant")
+	d.run("UAA client credentials grant", func() (string, string, error) {
+		var err error
+		clientToken, err = c.requestUAAToken(c.config.Notifications.ClientID, c.config.Notifications.ClientSecret, "client_credentials")
+		return "client " + c.config.Notifications.ClientID, "check notifications.client_id and notifications.client_secret", err
+	}, "Cloud Controller /v2/info")
+	d.run("notifications client token scopes", func() (string, string, error) {
+		return clientToken.Scope, "give the UAA client the authority " + strings.Join(notificationsClientScopes, ", "), requireScope(clientToken.Scope, notificationsClientScopes)
+	}, "UAA client credentials grant")
+
+	for _, destination := range c.destinations() {
+		orgCheck := "org " + destination.CFOrg
+		var orgGUID string
+		if !d.ran(orgCheck) {
+			d.run(orgCheck, func() (string, string, error) {
+				var err error
+				orgGUID, err = c.obtainGUIDUsingRequest(userToken.Token, createOrgQueryRequest(destination.CFOrg))
+				return "", fmt.Sprintf("check that the org exists and that %s is a member of it", c.config.CloudController.User), formattedCFError("org", destination.CFOrg, err)
+			}, "UAA password grant")
+		}
+		d.run(fmt.Sprintf("space %s in org %s", destination.CFSpace, destination.CFOrg), func() (string, string, error) {
+			if orgGUID == "" {
+				var err error
+				if orgGUID, err = c.obtainGUIDUsingRequest(userToken.Token, createOrgQueryRequest(destination.CFOrg)); err != nil {
+					return "", "", err
+				}
+			}
+			_, err := c.obtainGUIDUsingRequest(userToken.Token, createSpaceQueryRequest(orgGUID, destination.CFSpace))
+			return "", fmt.Sprintf("check that the space exists and that %s has the SpaceAuditor role in it", c.config.CloudController.User), formattedCFError("space", destination.CFSpace, err)
+		}, orgCheck)
+	}
+
+	d.run("notifications service reachable", func() (string, string, error) {
+		return c.config.Notifications.ServiceURL, "check notifications.service_url and that this VM can reach it", c.checkReachable(c.config.Notifications.ServiceURL, "/info")
+	}, "config")
+
+	return d.results
+}
+
+func (c *ServiceAlertsClient) validateConfig() error {
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"cloud_controller.url", c.config.CloudController.URL},
+		{"cloud_controller.user", c.config.CloudController.User},
+		{"cloud_controller.password", c.config.CloudController.Password},
+		{"notifications.service_url", c.config.Notifications.ServiceURL},
+		{"notifications.cf_org", c.config.Notifications.CFOrg},
+		{"notifications.cf_space", c.config.Notifications.CFSpace},
+		{"notifications.client_id", c.config.Notifications.ClientID},
+		{"notifications.client_secret", c.config.Notifications.ClientSecret},
+	}
+	var missing []string
+	for _, field := range required {
+		if field.value == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("not set: %s", strings.Join(missing, ", "))
+	}
+
+	if _, err := NewRouter(c.config); err != nil {
+		return err
+	}
+	if err := c.templatesErr; err != nil {
+		return err
+	}
+	return validateZeroRecipients(c.config.Notifications)
+}
+
+// validateLocales reports locales without a message catalog. These only
+// change the language of the email text, so no other check depends on it.
+func (c *ServiceAlertsClient) validateLocales() error {
+	locales := map[string]string{"notifications.locale": c.config.Notifications.Locale}
+	for _, receiver := range c.config.Receivers {
+		locales[fmt.Sprintf("locale of receiver %s", receiver.Name)] = receiver.Locale
+	}
+	var unknown []string
+	for setting, locale := range locales {
+		if _, ok := LookupLocale(locale); locale != "" && !ok {
+			unknown = append(unknown, fmt.Sprintf("%s %s", setting, locale))
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("no message catalog for %s, English would be used", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// checkReachable makes a single request, without retries, and only fails when
+// no response comes back or the server reports an error
+func (c *ServiceAlertsClient) checkReachable(baseURL, path string) error {
+	requestURL, err := joinURL(baseURL, path, "")
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// destinations lists the default space and every configured receiver's space
+func (c *ServiceAlertsClient) destinations() []Destination {
+	destinations := []Destination{c.DefaultDestination()}
+	seen := map[Destination]bool{c.DefaultDestination(): true}
+	for _, receiver := range c.config.Receivers {
+		if !seen[receiver.Destination] {
+			seen[receiver.Destination] = true
+			destinations = append(destinations, receiver.Destination)
+		}
+	}
+	return destinations
+}
+
+func requireScope(granted string, accepted []string) error {
+	for _, scope := range strings.Fields(granted) {
+		for _, want := range accepted {
+			if scope == want {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("token has none of the scopes %s", strings.Join(accepted, ", "))
+}
+
+type diagnosis struct {
+	results []Diagnostic
+}
+
+// run performs the check unless one of the checks it depends on did not pass
+func (d *diagnosis) run(name string, check func() (detail, hint string, err error), dependsOn ...string) {
+	for _, dependency := range dependsOn {
+		if d.status(dependency) != DiagnosticPass {
+			d.results = append(d.results, Diagnostic{Check: name, Status: DiagnosticSkip, Detail: dependency + " did not pass"})
+			return
+		}
+	}
+
+	detail, hint, err := check()
+	if err != nil {
+		d.results = append(d.results, Diagnostic{Check: name, Status: DiagnosticFail, Detail: err.Error(), Hint: hint})
+		return
+	}
+	d.results = append(d.results, Diagnostic{Check: name, Status: DiagnosticPass, Detail: detail})
+}
+
+func (d *diagnosis) ran(name string) bool {
+	return d.status(name) != ""
+}
+
+func (d *diagnosis) status(name string) DiagnosticStatus {
+	for _, result := range d.results {
+		if result.Check == name {
+			return result.Status
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// DryRunNotification is written for every notification that would have been
+// sent. SpaceGUID and URL are only set when GUIDs are resolved.
+type DryRunNotification struct {
+	Destination Destination              `json:"destination"`
+	SpaceGUID   string                   `json:"space_guid,omitempty"`
+	URL         string                   `json:"url,omitempty"`
+	Request     SpaceNotificationRequest `json:"request"`
+}
+
+func (c *ServiceAlertsClient) printNotification(destination Destination, notificationRequest SpaceNotificationRequest) error {
+	notification := DryRunNotification{Destination: destination, Request: notificationRequest}
+
+	if c.config.DryRunResolveGUIDs {
+		if err := c.setupUaaUrl(); err != nil {
+			return err
+		}
+		spaceGUID, err := c.obtainSpaceGUID(destination)
+		if err != nil {
+			return err
+		}
+		notification.SpaceGUID = spaceGUID
+		if notification.URL, err = joinURL(c.config.Notifications.ServiceURL, fmt.Sprintf("/spaces/%s", spaceGUID), ""); err != nil {
+			return err
+		}
+	}
+
+	output, err := json.MarshalIndent(notification, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, err = fmt.Fprintf(c.dryRunOutput, "%s\n", output)
+	return err
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dry run", func() {
+	var (
+		output *bytes.Buffer
+		client *ServiceAlertsClient
+	)
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		config := Config{
+			Notifications: Notifications{
+				ServiceURL: "http://notifications.invalid",
+				CFOrg:      "org",
+				CFSpace:    "space",
+				ReplyTo:    "ops@example.com",
+			},
+			Receivers: []Receiver{{Name: "redis-team", Destination: Destination{CFOrg: "org", CFSpace: "redis"}, Locale: "de"}},
+			Route: Route{
+				Routes: []Route{{Match: RouteMatch{Product: "redis"}, Receivers: []string{"default", "redis-team"}}},
+			},
+			DryRun: true,
+		}
+		client = New(config, log.New(GinkgoWriter, "", 0)).WithDryRunOutput(output)
+	})
+
+	It("writes the notification for every receiver without contacting Cloud Foundry", func() {
+		Expect(client.SendAlert(Alert{Product: "redis", Subject: "down", Content: "redis is down", Severity: SeverityCritical})).To(Succeed())
+
+		decoder := json.NewDecoder(output)
+		var notifications []DryRunNotification
+		for decoder.More() {
+			var notification DryRunNotification
+			Expect(decoder.Decode(&notification)).To(Succeed())
+			notifications = append(notifications, notification)
+		}
+
+		Expect(notifications).To(HaveLen(2))
+		Expect(notifications[0].Destination).To(Equal(Destination{CFOrg: "org", CFSpace: "space"}))
+		Expect(notifications[1].Destination).To(Equal(Destination{CFOrg: "org", CFSpace: "redis"}))
+		Expect(notifications[0].SpaceGUID).To(BeEmpty())
+		Expect(notifications[0].Request.KindID).To(Equal(DummyKindID))
+		Expect(notifications[0].Request.Subject).To(Equal("[Service Alert][redis] down"))
+		Expect(notifications[0].Request.Text).To(ContainSubstring("redis is down"))
+		Expect(notifications[0].Request.ReplyTo).To(Equal("ops@example.com"))
+		Expect(notifications[1].Request.Text).To(HavePrefix("Alarm von redis:"))
+	})
+
+	It("only shows the send time of alerts sent straight away", func() {
+		Expect(client.SendAlert(Alert{Product: "mysql", Subject: "down", Severity: SeverityCritical})).To(Succeed())
+
+		var notification DryRunNotification
+		Expect(json.NewDecoder(output).Decode(&notification)).To(Succeed())
+		Expect(notification.Request.Text).To(ContainSubstring("[Alert sent at "))
+		Expect(notification.Request.Text).NotTo(ContainSubstring("occurred"))
+	})
+
+	It("does not defer silenced alerts or start escalating them", func() {
+		dir, err := ioutil.TempDir("", "dry-run")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		config := client.config
+		config.SilencesFile = filepath.Join(dir, "silences.json")
+		config.Receivers = []Receiver{{Name: "ops", Destination: Destination{CFOrg: "org", CFSpace: "ops"}}}
+		config.Route = Route{}
+		config.EscalationPolicies = []EscalationPolicy{{Name: "all", Steps: []EscalationStep{{Receiver: "ops", DelaySeconds: 60}}}}
+		client = New(config, log.New(GinkgoWriter, "", 0)).WithDryRunOutput(output)
+
+		store := NewSilenceStore(config.SilencesFile)
+		silence, err := store.Create(Silence{Product: "redis", Action: SilenceActionDefer, StartsAt: time.Now().Add(-time.Hour), EndsAt: time.Now().Add(time.Hour)})
+		Expect(err).NotTo(HaveOccurred())
+
+		escalator, err := NewEscalator(config, &fakeAlertSender{}, newFakeClock(time.Now()), log.New(GinkgoWriter, "", 0))
+		Expect(err).NotTo(HaveOccurred())
+		dispatcher, err := NewDispatcher(client)
+		Expect(err).NotTo(HaveOccurred())
+		dispatcher.WithEscalator(escalator)
+
+		redis := Alert{Product: "redis", Subject: "down", Severity: SeverityCritical}
+		Expect(dispatcher.Dispatch(redis)).To(Succeed())
+		Expect(store.TakeDeferred(time.Now().Add(2 * time.Hour))).To(BeEmpty())
+
+		Expect(store.Expire(silence.ID, time.Now())).To(Succeed())
+		Expect(dispatcher.Dispatch(redis)).To(Succeed())
+		Expect(output.String()).To(ContainSubstring("[Service Alert][redis] down"))
+		Expect(escalator.Escalating(redis.Fingerprint())).To(BeFalse())
+	})
+})
@@ -8,32 +8,87 @@ package client
 
 import (
 	"bytes"
+	"sort"
 	"text/template"
 	"time"
 )
 
-var emailTemplateText = `Alert from {{.Product}}{{if .ServiceInstanceID}}, service instance {{.ServiceInstanceID}}{{end}}:
+var emailTemplateText = `{{.Text.AlertFrom}} {{.Product}}{{if .ServiceInstanceID}}, {{.Text.ServiceInstance}} {{.ServiceInstanceID}}{{end}}:
 
 {{.Content}}
 
-[Alert generated at {{.Timestamp}}]`
-var emailTemplate = template.Must(template.New("emailBody").Parse(emailTemplateText))
+{{if .AckURL}}{{.Text.Acknowledge}}: {{.AckURL}}
 
-func templateEmailBody(product, serviceInstanceID, content string, t time.Time) (string, error) {
+{{end}}[{{if .OccurredTimestamp}}{{.Text.AlertOccurredAt}} {{.OccurredTimestamp}}, {{.Text.SentAt}}{{else}}{{.Text.AlertSentAt}}{{end}} {{.Timestamp}}]`
+var emailTemplate = template.Must(template.New("body").Funcs(emailTemplateFuncs).Parse(emailTemplateText))
+
+var digestTemplateText = `{{printf .Text.DigestOf (len .Alerts)}}
+{{range .Groups}}
+{{$.Text.AlertsFrom}} {{.Product}}{{if .ServiceInstanceID}}, {{$.Text.ServiceInstance}} {{.ServiceInstanceID}}{{end}}:
+{{range .Alerts}}
+[{{.Severity}}] {{.Subject}}{{if .OccurredTimestamp}} ({{$.Text.OccurredAt}} {{.OccurredTimestamp}}){{end}}
+{{.Content}}
+{{end}}{{end}}
+[{{.Text.DigestSentAt}} {{.Timestamp}}]`
+var digestTemplate = template.Must(template.New("digestBody").Parse(digestTemplateText))
+
+type digestGroup struct {
+	Product           string
+	ServiceInstanceID string
+	Alerts            []digestAlert
+}
+
+type digestAlert struct {
+	Alert
+	OccurredTimestamp string
+}
+
+func templateDigestBody(alerts []Alert, locale string, t time.Time, timestamps timestampFormat) (string, error) {
+	catalog, _ := LookupLocale(locale)
 	var buffer bytes.Buffer
 	data := struct {
-		Product           string
-		ServiceInstanceID string
-		Content           string
-		Timestamp         string
+		Alerts    []Alert
+		Groups    []digestGroup
+		Timestamp string
+		Text      Messages
 	}{
-		product,
-		serviceInstanceID,
-		content,
-		t.Format(time.RFC3339),
+		alerts,
+		groupAlerts(alerts, timestamps),
+		timestamps.format(t),
+		catalog.Messages,
 	}
-	if err := emailTemplate.Execute(&buffer, data); err != nil {
+	if err := digestTemplate.Execute(&buffer, data); err != nil {
 		return "", err
 	}
 	return buffer.String(), nil
 }
+
+func groupAlerts(alerts []Alert, timestamps timestampFormat) []digestGroup {
+	var groups []digestGroup
+	for _, alert := range alerts {
+		entry := digestAlert{Alert: alert, OccurredTimestamp: timestamps.format(alert.OccurredAt)}
+		found := false
+		for i := range groups {
+			if groups[i].Product == alert.Product && groups[i].ServiceInstanceID == alert.ServiceInstanceID {
+				groups[i].Alerts = append(groups[i].Alerts, entry)
+				found = true
+				break
+			}
+		}
+		if !found {
+			groups = append(groups, digestGroup{
+				Product:           alert.Product,
+				ServiceInstanceID: alert.ServiceInstanceID,
+				Alerts:            []digestAlert{entry},
+			})
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Product != groups[j].Product {
+			return groups[i].Product < groups[j].Product
+		}
+		return groups[i].ServiceInstanceID < groups[j].ServiceInstanceID
+	})
+	return groups
+}
@@ -16,19 +16,59 @@ import (
 var _ = Describe("EmailBody", func() {
 	date := time.Date(2009, 11, 10, 23, 0, 1, 0, time.UTC)
 
+	render := func(alert Alert, ackURL string) string {
+		email, err := defaultEmailTemplates.Render(alert, DefaultLocale, ackURL, date)
+		Expect(err).NotTo(HaveOccurred())
+		return email.Text
+	}
+
 	It("templates out with all values", func() {
-		Expect(templateEmailBody("productName", "instanceId", "content", date)).To(Equal(`Alert from productName, service instance instanceId:
+		Expect(render(Alert{Product: "productName", ServiceInstanceID: "instanceId", Content: "content"}, "")).To(Equal(`Alert from productName, service instance instanceId:
 
 content
 
-[Alert generated at 2009-11-10T23:00:01Z]`))
+[Alert sent at 2009-11-10T23:00:01Z]`))
 	})
 
 	It("templates out without service instance", func() {
-		Expect(templateEmailBody("productName", "", "content", date)).To(Equal(`Alert from productName:
+		Expect(render(Alert{Product: "productName", Content: "content"}, "")).To(Equal(`Alert from productName:
+
+content
+
+[Alert sent at 2009-11-10T23:00:01Z]`))
+	})
+	It("templates out with an acknowledgement link", func() {
+		Expect(render(Alert{Product: "productName", Content: "content"}, "https://alerts.example.com/acknowledge?token=abc")).To(Equal(`Alert from productName:
 
 content
 
-[Alert generated at 2009-11-10T23:00:01Z]`))
+Acknowledge this alert: https://alerts.example.com/acknowledge?token=abc
+
+[Alert sent at 2009-11-10T23:00:01Z]`))
+	})
+
+	It("templates a digest grouped by product and service instance", func() {
+		alerts := []Alert{
+			{Product: "redis", ServiceInstanceID: "b", Subject: "memory", Content: "high memory", Severity: SeverityInfo},
+			{Product: "mysql", Subject: "backup", Content: "backup slow", Severity: SeverityWarning, OccurredAt: date.Add(-time.Hour)},
+			{Product: "redis", ServiceInstanceID: "b", Subject: "clients", Content: "many clients", Severity: SeverityInfo},
+		}
+
+		Expect(templateDigestBody(alerts, DefaultLocale, date, defaultTimestampFormat)).To(Equal(`Digest of 3 alerts
+
+Alerts from mysql:
+
+[warning] backup (occurred at 2009-11-10T22:00:01Z)
+backup slow
+
+Alerts from redis, service instance b:
+
+[info] memory
+high memory
+
+[info] clients
+many clients
+
+[Digest sent at 2009-11-10T23:00:01Z]`))
 	})
 })
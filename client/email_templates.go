@@ -0,0 +1,339 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultSubjectTemplateText = `[Service Alert][{{.Product}}]{{if .Resolved}}[Resolved]{{end}} {{.Subject}}`
+	defaultBrandName           = "Service Alerts"
+	defaultBrandColor          = "#1f5c99"
+)
+
+var brandColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+var emailHTMLLayout = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Subject}}</title>
+</head>
+<body style="margin:0;padding:0;background-color:#f4f4f4;font-family:Helvetica,Arial,sans-serif;color:#333333;">
+<table role="presentation" width="100%" cellpadding="0" cellspacing="0" style="max-width:640px;margin:0 auto;">
+<tr><td style="background-color:{{.BrandColor}};color:#ffffff;padding:16px 24px;font-size:18px;font-weight:bold;">{{.BrandName}}</td></tr>
+<tr><td style="background-color:#ffffff;padding:24px;">
+<h2 style="margin-top:0;">{{if .Resolved}}{{.Text.Resolved}}: {{end}}{{.Subject}}</h2>
+<p style="color:#666666;">{{.Text.AlertFrom}} {{.Product}}{{if .ServiceInstanceID}}, {{.Text.ServiceInstance}} {{.ServiceInstanceID}}{{end}}</p>
+{{.ContentHTML}}{{if .AckURL}}<p><a href="{{.AckURL}}" style="display:inline-block;padding:8px 16px;background-color:{{.BrandColor}};color:#ffffff;text-decoration:none;">{{.Text.Acknowledge}}</a></p>
+{{end}}</td></tr>
+<tr><td style="padding:16px 24px;font-size:12px;color:#999999;">{{if .OccurredTimestamp}}{{.Text.AlertOccurredAt}} {{.OccurredTimestamp}}, {{.Text.SentAt}}{{else}}{{.Text.AlertSentAt}}{{end}} {{.Timestamp}}</td></tr>
+</table>
+</body>
+</html>
+`))
+
+// EmailData is what subject and body templates are executed against. Time
+// is when the email is sent and OccurredAt when the alert occurred, both in
+// the display timezone; Timestamp and OccurredTimestamp are them formatted
+// with the timestamp layout, and OccurredTimestamp is empty when the
+// occurrence time is not known. Text is the fixed text of the built-in
+// templates in the language of Locale.
+type EmailData struct {
+	Product           string
+	ServiceInstanceID string
+	Subject           string
+	Content           string
+	Severity          Severity
+	Labels            map[string]string
+	Resolved          bool
+	AckURL            string
+	Time              time.Time
+	Timestamp         string
+	OccurredAt        time.Time
+	OccurredTimestamp string
+	Locale            string
+	Text              Messages
+}
+
+// RenderedEmail is the subject and bodies of an alert email. HTML is empty
+// unless HTML emails are enabled.
+type RenderedEmail struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// EmailTemplates renders the subject and body of alert emails, using the
+// first override matching the alert's product and severity
+type EmailTemplates struct {
+	subject    *template.Template
+	body       *template.Template
+	overrides  []emailTemplateOverride
+	html       EmailHTML
+	timestamps timestampFormat
+}
+
+// timestampFormat renders times in the display timezone and layout. Without
+// a timezone times keep their own, which is the local one for the send time.
+type timestampFormat struct {
+	location *time.Location
+	layout   string
+}
+
+var defaultTimestampFormat = timestampFormat{layout: time.RFC3339}
+
+func (f timestampFormat) in(t time.Time) time.Time {
+	if t.IsZero() || f.location == nil {
+		return t
+	}
+	return t.In(f.location)
+}
+
+func (f timestampFormat) format(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return f.in(t).Format(f.layout)
+}
+
+type emailTemplateOverride struct {
+	product  string
+	severity *Severity
+	subject  *template.Template
+	body     *template.Template
+}
+
+var emailTemplateFuncs = template.FuncMap{
+	"date": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"truncate": func(length int, text string) string {
+		runes := []rune(text)
+		if length < 0 || len(runes) <= length {
+			return text
+		}
+		return string(runes[:length]) + "..."
+	},
+	"indent": func(spaces int, text string) string {
+		padding := strings.Repeat(" ", spaces)
+		return padding + strings.Replace(text, "\n", "\n"+padding, -1)
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+var defaultEmailTemplates = &EmailTemplates{
+	subject:    template.Must(template.New("subject").Funcs(emailTemplateFuncs).Parse(defaultSubjectTemplateText)),
+	body:       emailTemplate,
+	timestamps: defaultTimestampFormat,
+}
+
+// NewEmailTemplates loads the configured templates, falling back to the
+// built-in ones, and checks them by rendering a sample alert so that mistakes
+// are reported at startup rather than when an alert is sent. Errors include
+// the template name, which is the file path for templates loaded from files,
+// and the line number.
+func NewEmailTemplates(config Templates) (*EmailTemplates, error) {
+	templates := &EmailTemplates{html: config.HTML, timestamps: defaultTimestampFormat}
+	if config.Timezone != "" {
+		location, err := time.LoadLocation(config.Timezone)
+		if err != nil {
+			return nil, ConfigError{Section: "templates", Err: fmt.Errorf("timezone: %s", err)}
+		}
+		templates.timestamps.location = location
+	}
+	if config.TimestampLayout != "" {
+		templates.timestamps.layout = config.TimestampLayout
+	}
+	if templates.html.BrandName == "" {
+		templates.html.BrandName = defaultBrandName
+	}
+	if templates.html.BrandColor == "" {
+		templates.html.BrandColor = defaultBrandColor
+	}
+	if !brandColorPattern.MatchString(templates.html.BrandColor) {
+		return nil, ConfigError{Section: "templates", Err: fmt.Errorf("html.brand_color must be a hex color such as %s", defaultBrandColor)}
+	}
+
+	var err error
+	if templates.subject, err = parseEmailTemplate("templates.subject", config.Subject, config.SubjectFile, defaultEmailTemplates.subject); err != nil {
+		return nil, ConfigError{Section: "templates", Err: err}
+	}
+	if templates.body, err = parseEmailTemplate("templates.body", config.Body, config.BodyFile, defaultEmailTemplates.body); err != nil {
+		return nil, ConfigError{Section: "templates", Err: err}
+	}
+
+	for i, override := range config.Overrides {
+		name := fmt.Sprintf("templates.overrides[%d]", i)
+		parsed := emailTemplateOverride{product: override.Product}
+		if override.Product == "" && override.Severity == "" {
+			return nil, ConfigError{Section: "templates", Err: fmt.Errorf("%s needs a product or a severity", name)}
+		}
+		if override.Severity != "" {
+			severity, err := ParseSeverity(override.Severity)
+			if err != nil {
+				return nil, ConfigError{Section: "templates", Err: fmt.Errorf("%s: %s", name, err)}
+			}
+			parsed.severity = &severity
+		}
+		if parsed.subject, err = parseEmailTemplate(name+".subject", override.Subject, override.SubjectFile, templates.subject); err != nil {
+			return nil, ConfigError{Section: "templates", Err: err}
+		}
+		if parsed.body, err = parseEmailTemplate(name+".body", override.Body, override.BodyFile, templates.body); err != nil {
+			return nil, ConfigError{Section: "templates", Err: err}
+		}
+		templates.overrides = append(templates.overrides, parsed)
+	}
+
+	if err := templates.validate(); err != nil {
+		return nil, ConfigError{Section: "templates", Err: err}
+	}
+	return templates, nil
+}
+
+func parseEmailTemplate(name, text, file string, fallback *template.Template) (*template.Template, error) {
+	if text != "" && file != "" {
+		return nil, fmt.Errorf("%s: only one of the template and its file may be set", name)
+	}
+	if file != "" {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		name, text = file, string(contents)
+	}
+	if text == "" {
+		return fallback, nil
+	}
+	return template.New(name).Funcs(emailTemplateFuncs).Parse(text)
+}
+
+func (t *EmailTemplates) validate() error {
+	sample := EmailData{
+		Product:           "product",
+		ServiceInstanceID: "service-instance",
+		Subject:           "subject",
+		Content:           "content",
+		Severity:          SeverityCritical,
+		Labels:            map[string]string{},
+		AckURL:            "https://example.com/acknowledge",
+		Time:              t.timestamps.in(time.Unix(0, 0)),
+		Timestamp:         t.timestamps.format(time.Unix(0, 0)),
+		OccurredAt:        t.timestamps.in(time.Unix(0, 0)),
+		OccurredTimestamp: t.timestamps.format(time.Unix(0, 0)),
+		Locale:            DefaultLocale,
+		Text:              locales[DefaultLocale].Messages,
+	}
+
+	templates := []*template.Template{t.subject, t.body}
+	for _, override := range t.overrides {
+		templates = append(templates, override.subject, override.body)
+	}
+	for _, tmpl := range templates {
+		if _, err := executeEmailTemplate(tmpl, sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render returns the subject and bodies of the email for the alert sent at
+// the given time, with the fixed text of the built-in templates in the given
+// locale or, when there is no catalog for it, in English. The HTML
+// body is the alert content rendered as Markdown inside a branded layout,
+// while the text body, which mail clients fall back to, comes from the body
+// template.
+func (t *EmailTemplates) Render(alert Alert, locale, ackURL string, at time.Time) (RenderedEmail, error) {
+	catalog, _ := LookupLocale(locale)
+	data := EmailData{
+		Product:           alert.Product,
+		ServiceInstanceID: alert.ServiceInstanceID,
+		Subject:           alert.Subject,
+		Content:           alert.Content,
+		Severity:          alert.Severity,
+		Labels:            alert.Labels,
+		Resolved:          alert.Resolved,
+		AckURL:            ackURL,
+		Time:              t.timestamps.in(at),
+		Timestamp:         t.timestamps.format(at),
+		OccurredAt:        t.timestamps.in(alert.OccurredAt),
+		OccurredTimestamp: t.timestamps.format(alert.OccurredAt),
+		Locale:            catalog.Code,
+		Text:              catalog.Messages,
+	}
+
+	subjectTemplate, bodyTemplate := t.subject, t.body
+	for _, override := range t.overrides {
+		if override.matches(alert) {
+			subjectTemplate, bodyTemplate = override.subject, override.body
+			break
+		}
+	}
+
+	subject, err := executeEmailTemplate(subjectTemplate, data)
+	if err != nil {
+		return RenderedEmail{}, err
+	}
+	text, err := executeEmailTemplate(bodyTemplate, data)
+	if err != nil {
+		return RenderedEmail{}, err
+	}
+	email := RenderedEmail{Subject: strings.TrimSpace(subject), Text: text}
+
+	if t.html.Enabled {
+		var buffer bytes.Buffer
+		err := emailHTMLLayout.Execute(&buffer, struct {
+			EmailData
+			BrandName   string
+			BrandColor  string
+			ContentHTML htmltemplate.HTML
+		}{
+			data,
+			t.html.BrandName,
+			t.html.BrandColor,
+			htmltemplate.HTML(renderMarkdown(alert.Content)),
+		})
+		if err != nil {
+			return RenderedEmail{}, err
+		}
+		email.HTML = buffer.String()
+	}
+	return email, nil
+}
+
+func (o emailTemplateOverride) matches(alert Alert) bool {
+	if o.product != "" && o.product != alert.Product {
+		return false
+	}
+	if o.severity != nil && *o.severity != alert.Severity {
+		return false
+	}
+	return true
+}
+
+func executeEmailTemplate(tmpl *template.Template, data EmailData) (string, error) {
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
@@ -0,0 +1,182 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EmailTemplates", func() {
+	date := time.Date(2009, 11, 10, 23, 0, 1, 0, time.UTC)
+	alert := Alert{Product: "redis", ServiceInstanceID: "instance", Subject: "down", Content: "line one\nline two", Severity: SeverityCritical}
+
+	It("uses the built-in templates by default", func() {
+		templates, err := NewEmailTemplates(Templates{})
+		Expect(err).NotTo(HaveOccurred())
+
+		email, err := templates.Render(alert, "en", "", date)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(email.Subject).To(Equal("[Service Alert][redis] down"))
+		Expect(email.Text).To(HavePrefix("Alert from redis, service instance instance:"))
+
+		alert := alert
+		alert.Resolved = true
+		email, err = templates.Render(alert, "en", "", date)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(email.Subject).To(Equal("[Service Alert][redis][Resolved] down"))
+	})
+
+	It("renders configured templates with the helper functions", func() {
+		templates, err := NewEmailTemplates(Templates{
+			Subject: `{{upper .Severity.String}}: {{truncate 2 .Subject}}`,
+			Body:    `At {{date "2006-01-02 15:04" .Time}}:{{"\n"}}{{indent 2 .Content}}`,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		email, err := templates.Render(alert, "en", "", date)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(email.Subject).To(Equal("CRITICAL: do..."))
+		Expect(email.Text).To(Equal("At 2009-11-10 23:00:\n  line one\n  line two"))
+	})
+
+	It("uses the first override matching the product and severity", func() {
+		templates, err := NewEmailTemplates(Templates{
+			Subject: "default",
+			Overrides: []TemplateOverride{
+				{Product: "redis", Severity: "warning", Subject: "redis warning"},
+				{Product: "redis", Subject: "redis", Body: "redis body"},
+				{Severity: "critical", Subject: "critical"},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		email, err := templates.Render(alert, "en", "", date)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(email.Subject).To(Equal("redis"))
+		Expect(email.Text).To(Equal("redis body"))
+
+		email, err = templates.Render(Alert{Product: "mysql", Severity: SeverityCritical}, "en", "", date)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(email.Subject).To(Equal("critical"))
+		Expect(email.Text).To(HavePrefix("Alert from mysql:"))
+
+		email, err = templates.Render(Alert{Product: "mysql", Severity: SeverityInfo}, "en", "", date)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(email.Subject).To(Equal("default"))
+	})
+
+	It("renders the occurrence and send times in the display timezone and layout", func() {
+		templates, err := NewEmailTemplates(Templates{Timezone: "America/New_York", TimestampLayout: "2006-01-02 15:04 MST"})
+		Expect(err).NotTo(HaveOccurred())
+
+		alert := alert
+		alert.OccurredAt = date.Add(-time.Hour)
+		email, err := templates.Render(alert, "en", "", date)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(email.Text).To(HaveSuffix("[Alert occurred at 2009-11-10 17:00 EST, sent at 2009-11-10 18:00 EST]"))
+	})
+
+	It("rejects unknown timezones", func() {
+		_, err := NewEmailTemplates(Templates{Timezone: "Mars/Olympus_Mons"})
+		Expect(err).To(MatchError(ContainSubstring("invalid templates config: timezone: unknown time zone Mars/Olympus_Mons")))
+	})
+
+	Context("with templates in files", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "templates")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("loads them", func() {
+			path := filepath.Join(dir, "body.tmpl")
+			Expect(ioutil.WriteFile(path, []byte("{{.Product}} says {{.Content}}"), 0600)).To(Succeed())
+
+			templates, err := NewEmailTemplates(Templates{BodyFile: path})
+			Expect(err).NotTo(HaveOccurred())
+			email, err := templates.Render(Alert{Product: "redis", Content: "hi"}, "en", "", date)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(email.Text).To(Equal("redis says hi"))
+		})
+
+		It("reports errors with the file and line", func() {
+			path := filepath.Join(dir, "body.tmpl")
+			Expect(ioutil.WriteFile(path, []byte("line one\n{{.Product}\n"), 0600)).To(Succeed())
+
+			_, err := NewEmailTemplates(Templates{BodyFile: path})
+			Expect(err).To(MatchError(ContainSubstring(path + ":2:")))
+		})
+	})
+
+	It("reports unknown fields with the line when validating", func() {
+		_, err := NewEmailTemplates(Templates{Overrides: []TemplateOverride{{Product: "redis", Body: "ok\n{{.Nope}}"}}})
+		Expect(err).To(MatchError(ContainSubstring("templates.overrides[0].body:2:")))
+		Expect(err).To(MatchError(ContainSubstring("can't evaluate field Nope")))
+	})
+
+	It("rejects overrides without a product or severity", func() {
+		_, err := NewEmailTemplates(Templates{Overrides: []TemplateOverride{{Subject: "x"}}})
+		Expect(err).To(MatchError("invalid templates config: templates.overrides[0] needs a product or a severity"))
+	})
+
+	It("rejects a template set both inline and as a file", func() {
+		_, err := NewEmailTemplates(Templates{Subject: "x", SubjectFile: "/tmp/subject"})
+		Expect(err).To(MatchError("invalid templates config: templates.subject: only one of the template and its file may be set"))
+	})
+
+	Describe("HTML emails", func() {
+		It("are not rendered unless enabled", func() {
+			templates, err := NewEmailTemplates(Templates{})
+			Expect(err).NotTo(HaveOccurred())
+			email, err := templates.Render(alert, "en", "", date)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(email.HTML).To(BeEmpty())
+		})
+
+		It("render Markdown content into the layout with the text body as fallback", func() {
+			content, err := ioutil.ReadFile(filepath.Join("fixtures", "alert_content.md"))
+			Expect(err).NotTo(HaveOccurred())
+
+			templates, err := NewEmailTemplates(Templates{HTML: EmailHTML{Enabled: true, BrandName: "Acme <Ops>"}})
+			Expect(err).NotTo(HaveOccurred())
+			alert := Alert{Product: "redis", ServiceInstanceID: "instance", Subject: "Backup failed", Content: string(content)}
+			email, err := templates.Render(alert, "en", "https://alerts.example.com/acknowledge?token=abc&x=1", date)
+			Expect(err).NotTo(HaveOccurred())
+
+			expectGolden(filepath.Join("fixtures", "alert_email.txt"), email.Text)
+			expectGolden(filepath.Join("fixtures", "alert_email.html"), email.HTML)
+		})
+
+		It("rejects brand colors that are not hex colors", func() {
+			_, err := NewEmailTemplates(Templates{HTML: EmailHTML{Enabled: true, BrandColor: "red;background:url(x)"}})
+			Expect(err).To(MatchError("invalid templates config: html.brand_color must be a hex color such as #1f5c99"))
+		})
+	})
+})
+
+// expectGolden compares output with a golden file, rewriting the file instead
+// when UPDATE_GOLDEN is set
+func expectGolden(path, actual string) {
+	if os.Getenv("UPDATE_GOLDEN") != "" {
+		Expect(ioutil.WriteFile(path, []byte(actual), 0644)).To(Succeed())
+	}
+	expected, err := ioutil.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(actual).To(Equal(string(expected)), "output differs from "+path+", run with UPDATE_GOLDEN=1 to update it")
+}
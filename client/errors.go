@@ -0,0 +1,134 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The errors below tell callers why an alert could not be sent. They wrap
+// their cause and may themselves be wrapped, for example in an
+// HTTPRequestError, so look for them with errors.As.
+
+// AuthError is returned when UAA rejects the configured credentials, or the
+// CF API or the notifications service rejects a token, with HTTP 401 or 403.
+// Label says which of them it was.
+type AuthError struct {
+	Label      string
+	StatusCode int
+	Err        error
+}
+
+func (e AuthError) Error() string { return e.Err.Error() }
+func (e AuthError) Unwrap() error { return e.Err }
+
+// NotFoundError is returned when the org or space alerts are sent to does not
+// exist or is not visible to the CF user. Resource is "org" or "space".
+type NotFoundError struct {
+	Resource string
+	Name     string
+	Err      error
+}
+
+func (e NotFoundError) Error() string {
+	return fmt.Sprintf("CF %s not found: '%s'", e.Resource, e.Name)
+}
+func (e NotFoundError) Unwrap() error { return e.Err }
+
+// RejectedError is returned when a request is refused with a 4xx status other
+// than 401 or 403, for example a notification the notifications service does
+// not accept. Such requests are not retried.
+type RejectedError struct {
+	Label      string
+	StatusCode int
+	Err        error
+}
+
+func (e RejectedError) Error() string { return e.Err.Error() }
+func (e RejectedError) Unwrap() error { return e.Err }
+
+// TimeoutError is returned when an alert was not sent within timeout_seconds,
+// in which case Err is the last failed attempt, if there was one. It is also
+// the cause of a failed attempt when a single request took too long, in which
+// case Label says which request.
+type TimeoutError struct {
+	Label   string
+	Timeout time.Duration
+	Err     error
+}
+
+func (e TimeoutError) Error() string {
+	switch {
+	case e.Label != "":
+		return fmt.Sprintf("%s request timed out after %s: %s", e.Label, e.Timeout, e.Err)
+	case e.Err != nil:
+		return fmt.Sprintf("sending service alert timed out after %s: %s", e.Timeout, e.Err)
+	default:
+		return fmt.Sprintf("sending service alert timed out after %s", e.Timeout)
+	}
+}
+func (e TimeoutError) Unwrap() error { return e.Err }
+
+// UnreachableError is returned when a connection to the CF API, UAA or the
+// notifications service could not be made, or failed, until retries were
+// given up
+type UnreachableError struct {
+	Label string
+	Err   error
+}
+
+func (e UnreachableError) Error() string { return e.Err.Error() }
+func (e UnreachableError) Unwrap() error { return e.Err }
+
+// ConfigError is returned for config that cannot be used. Section is the part
+// of the config at fault, such as "routing", or empty for the file as a whole.
+type ConfigError struct {
+	Section string
+	Err     error
+}
+
+func (e ConfigError) Error() string {
+	if e.Section == "" {
+		return fmt.Sprintf("invalid config: %s", e.Err)
+	}
+	return fmt.Sprintf("invalid %s config: %s", e.Section, e.Err)
+}
+func (e ConfigError) Unwrap() error { return e.Err }
+
+// ReceiverErrors is returned when sending an alert failed for some of the
+// receivers it was routed to, by receiver name. The other receivers were sent
+// the alert.
+type ReceiverErrors map[string]error
+
+func (e ReceiverErrors) Error() string {
+	var failures []string
+	for _, name := range e.Receivers() {
+		failures = append(failures, fmt.Sprintf("receiver %s: %s", name, e[name]))
+	}
+	return "failed to send alert to " + strings.Join(failures, "; ")
+}
+
+func (e ReceiverErrors) Unwrap() []error {
+	var errs []error
+	for _, name := range e.Receivers() {
+		errs = append(errs, e[name])
+	}
+	return errs
+}
+
+// Receivers returns the names of the receivers that failed, sorted
+func (e ReceiverErrors) Receivers() []string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,92 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("errors", func() {
+	var (
+		server     *httptest.Server
+		status     int
+		httpClient *RetryHTTPClient
+	)
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(status)
+		}))
+		httpClient = NewRetryHTTPClient(Config{GlobalTimeoutSeconds: 1}, log.New(GinkgoWriter, "", 0))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	get := func() error {
+		req, err := http.NewRequest("GET", server.URL, nil)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = httpClient.doRequestWithRetries("CF API", req)
+		return err
+	}
+
+	It("reports rejected credentials as an AuthError", func() {
+		status = http.StatusForbidden
+
+		var authErr AuthError
+		Expect(errors.As(get(), &authErr)).To(BeTrue())
+		Expect(authErr.Label).To(Equal("CF API"))
+		Expect(authErr.StatusCode).To(Equal(http.StatusForbidden))
+	})
+
+	It("reports other 4xx responses as a RejectedError", func() {
+		status = http.StatusUnprocessableEntity
+
+		var rejectedErr RejectedError
+		Expect(errors.As(get(), &rejectedErr)).To(BeTrue())
+		Expect(rejectedErr.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+		Expect(rejectedErr).To(MatchError(ContainSubstring("CF API expected to return HTTP 200, got 422.")))
+	})
+
+	It("reports connection failures as an UnreachableError inside an HTTPRequestError", func() {
+		server.Close()
+
+		err := get()
+		var httpErr HTTPRequestError
+		Expect(errors.As(err, &httpErr)).To(BeTrue())
+		var unreachableErr UnreachableError
+		Expect(errors.As(err, &unreachableErr)).To(BeTrue())
+		Expect(unreachableErr.Label).To(Equal("CF API"))
+	})
+
+	It("remembers the last failure for timeouts", func() {
+		status = http.StatusInternalServerError
+		before := time.Now()
+
+		err := get()
+		Expect(err).To(HaveOccurred())
+		Expect(httpClient.failureSince(before)).To(Equal(err))
+		Expect(httpClient.failureSince(time.Now())).To(BeNil())
+	})
+
+	It("reports invalid config as a ConfigError", func() {
+		_, err := NewRouter(Config{Route: Route{Receivers: []string{"nobody"}}})
+
+		var configErr ConfigError
+		Expect(errors.As(err, &configErr)).To(BeTrue())
+		Expect(configErr.Section).To(Equal("routing"))
+		Expect(err).To(MatchError("invalid routing config: unknown receiver: 'nobody'"))
+	})
+})
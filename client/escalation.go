@@ -0,0 +1,251 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+type AlertSender interface {
+	SendAlertTo(destination Destination, alert Alert) error
+}
+
+// Escalator sends an alert to the receiver of each step of the first matching
+// escalation policy in turn, waiting for the step's delay after the previous
+// step, until the alert is resolved or acknowledged
+type Escalator struct {
+	sender    AlertSender
+	policies  []EscalationPolicy
+	receivers map[string]Receiver
+	clock     clock.Clock
+	logger    *log.Logger
+	state     StateStore
+	silences  *SilenceStore
+
+	mutex       sync.Mutex
+	escalations map[string]chan struct{}
+	stopped     bool
+	running     sync.WaitGroup
+}
+
+func NewEscalator(config Config, sender AlertSender, clock clock.Clock, logger *log.Logger) (*Escalator, error) {
+	receivers, err := configuredReceivers(config)
+	if err != nil {
+		return nil, ConfigError{Section: "escalation", Err: err}
+	}
+
+	for _, policy := range config.EscalationPolicies {
+		if policy.Match.Severity != "" {
+			if _, err := ParseSeverity(policy.Match.Severity); err != nil {
+				return nil, ConfigError{Section: "escalation", Err: err}
+			}
+		}
+		for _, step := range policy.Steps {
+			if _, ok := receivers[step.Receiver]; !ok {
+				return nil, ConfigError{Section: "escalation", Err: fmt.Errorf("unknown receiver: '%s'", step.Receiver)}
+			}
+			if step.DelaySeconds < 0 {
+				return nil, ConfigError{Section: "escalation", Err: fmt.Errorf("delay_seconds must not be negative")}
+			}
+		}
+	}
+
+	return &Escalator{
+		sender:      sender,
+		policies:    config.EscalationPolicies,
+		receivers:   receivers,
+		clock:       clock,
+		logger:      logger,
+		escalations: map[string]chan struct{}{},
+	}, nil
+}
+
+// WithStateStore makes the escalator stop before any step once the alert has
+// been acknowledged in the store, for example through an acknowledgement link
+func (e *Escalator) WithStateStore(store StateStore) *Escalator {
+	e.state = store
+	return e
+}
+
+// WithSilences makes the escalator stop before any step once a silence in the
+// store matches the alert, so that nobody is paged during a maintenance window
+func (e *Escalator) WithSilences(store *SilenceStore) *Escalator {
+	e.silences = store
+	return e
+}
+
+// Start begins escalating the alert under the first policy matching it. It
+// returns false when no policy matches or the alert is already escalating.
+func (e *Escalator) Start(alert Alert) bool {
+	if alert.OccurredAt.IsZero() {
+		alert.OccurredAt = e.clock.Now()
+	}
+	for _, policy := range e.policies {
+		if policy.Match.matches(alert) {
+			return e.start(policy, alert)
+		}
+	}
+	return false
+}
+
+// Resolve stops escalating the alert and forgets its acknowledgement in the
+// state store, so that it is escalated again when it next fires
+func (e *Escalator) Resolve(fingerprint string) bool {
+	if e.state != nil {
+		if _, err := e.state.Resolve(fingerprint); err != nil {
+			e.logger.Printf("Failed to forget acknowledgement of alert %s: %s", fingerprint, err)
+		}
+	}
+	return e.stop(fingerprint, "resolved")
+}
+
+func (e *Escalator) Acknowledge(fingerprint string) bool {
+	return e.stop(fingerprint, "acknowledged")
+}
+
+// Stop ends every escalation and waits until none is sending any more. Alerts
+// are not escalated after it returns.
+func (e *Escalator) Stop() {
+	e.mutex.Lock()
+	e.stopped = true
+	for fingerprint, stop := range e.escalations {
+		close(stop)
+		delete(e.escalations, fingerprint)
+	}
+	e.mutex.Unlock()
+
+	e.running.Wait()
+}
+
+func (e *Escalator) Escalating(fingerprint string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	_, ok := e.escalations[fingerprint]
+	return ok
+}
+
+func (e *Escalator) start(policy EscalationPolicy, alert Alert) bool {
+	fingerprint := alert.Fingerprint()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, ok := e.escalations[fingerprint]; ok || e.stopped {
+		return false
+	}
+
+	stop := make(chan struct{})
+	e.escalations[fingerprint] = stop
+	e.running.Add(1)
+	go e.escalate(policy, alert, stop)
+	return true
+}
+
+func (e *Escalator) escalate(policy EscalationPolicy, alert Alert, stop <-chan struct{}) {
+	fingerprint := alert.Fingerprint()
+	defer e.running.Done()
+	defer e.finish(fingerprint, stop)
+
+	for i, step := range policy.Steps {
+		if !e.wait(time.Duration(step.DelaySeconds)*time.Second, stop) {
+			return
+		}
+		if e.acknowledged(fingerprint) {
+			e.logger.Printf("Stopping escalation of alert %s: acknowledged", fingerprint)
+			return
+		}
+		if silence := e.silence(alert); silence != nil {
+			e.logger.Printf("Stopping escalation of alert %s: silenced by %s", fingerprint, silence.ID)
+			return
+		}
+
+		receiver := e.receivers[step.Receiver]
+		e.logger.Printf("Escalating alert %s to receiver %s (policy %s, step %d)", fingerprint, receiver.Name, policy.Name, i+1)
+		if err := e.sender.SendAlertTo(receiver.Destination, alert); err != nil {
+			e.logger.Printf("Failed to escalate alert %s to receiver %s: %s", fingerprint, receiver.Name, err)
+		}
+	}
+}
+
+func (e *Escalator) acknowledged(fingerprint string) bool {
+	if e.state == nil {
+		return false
+	}
+
+	acknowledged, err := e.state.Acknowledged(fingerprint)
+	if err != nil {
+		e.logger.Printf("Failed to check whether alert %s was acknowledged: %s", fingerprint, err)
+		return false
+	}
+	return acknowledged
+}
+
+func (e *Escalator) silence(alert Alert) *Silence {
+	if e.silences == nil {
+		return nil
+	}
+
+	silence, err := e.silences.Active(alert, e.clock.Now())
+	if err != nil {
+		e.logger.Printf("Failed to check whether alert %s is silenced: %s", alert.Fingerprint(), err)
+		return nil
+	}
+	return silence
+}
+
+func (e *Escalator) wait(delay time.Duration, stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return false
+	default:
+	}
+	if delay <= 0 {
+		return true
+	}
+
+	timer := e.clock.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+func (e *Escalator) stop(fingerprint, reason string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	stop, ok := e.escalations[fingerprint]
+	if !ok {
+		return false
+	}
+
+	e.logger.Printf("Stopping escalation of alert %s: %s", fingerprint, reason)
+	close(stop)
+	delete(e.escalations, fingerprint)
+	return true
+}
+
+// finish forgets an escalation that ran out of steps, unless it has already
+// been stopped and possibly restarted
+func (e *Escalator) finish(fingerprint string, stop <-chan struct{}) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if current, ok := e.escalations[fingerprint]; ok && current == stop {
+		delete(e.escalations, fingerprint)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeAlertSender struct {
+	mutex sync.Mutex
+	sent  []Destination
+}
+
+func (f *fakeAlertSender) SendAlertTo(destination Destination, alert Alert) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.sent = append(f.sent, destination)
+	return nil
+}
+
+func (f *fakeAlertSender) Sent() []Destination {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]Destination{}, f.sent...)
+}
+
+var _ = Describe("Escalator", func() {
+	var (
+		sender    *fakeAlertSender
+		clock     *fakeClock
+		escalator *Escalator
+		config    Config
+		opsSpace  = Destination{CFOrg: "org", CFSpace: "ops"}
+		onCall    = Destination{CFOrg: "org", CFSpace: "on-call"}
+		alert     = Alert{Product: "redis", Subject: "down", Severity: SeverityCritical}
+	)
+
+	BeforeEach(func() {
+		sender = &fakeAlertSender{}
+		clock = newFakeClock(time.Date(2016, 10, 1, 0, 0, 0, 0, time.UTC))
+		config = Config{
+			Receivers: []Receiver{
+				{Name: "ops", Destination: opsSpace},
+				{Name: "on-call", Destination: onCall},
+			},
+			EscalationPolicies: []EscalationPolicy{{
+				Name:  "critical",
+				Match: RouteMatch{Severity: "critical"},
+				Steps: []EscalationStep{
+					{Receiver: "ops", DelaySeconds: 600},
+					{Receiver: "on-call", DelaySeconds: 900},
+				},
+			}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		escalator, err = NewEscalator(config, sender, clock, log.New(GinkgoWriter, "", 0))
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("sends to each step's receiver after its delay", func() {
+		Expect(escalator.Start(alert)).To(BeTrue())
+
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(599 * time.Second)
+		Consistently(sender.Sent).Should(BeEmpty())
+
+		clock.Increment(time.Second)
+		Eventually(sender.Sent).Should(Equal([]Destination{opsSpace}))
+
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(900 * time.Second)
+		Eventually(sender.Sent).Should(Equal([]Destination{opsSpace, onCall}))
+		Eventually(func() bool { return escalator.Escalating(alert.Fingerprint()) }).Should(BeFalse())
+	})
+
+	It("stops escalating once the alert is acknowledged", func() {
+		Expect(escalator.Start(alert)).To(BeTrue())
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(600 * time.Second)
+		Eventually(sender.Sent).Should(HaveLen(1))
+		Eventually(clock.WatcherCount).Should(Equal(1))
+
+		Expect(escalator.Acknowledge(alert.Fingerprint())).To(BeTrue())
+		Eventually(clock.WatcherCount).Should(BeZero())
+		clock.Increment(time.Hour)
+		Consistently(sender.Sent).Should(HaveLen(1))
+	})
+
+	It("stops escalating once the alert is acknowledged in the state store", func() {
+		store := NewMemoryStateStore()
+		escalator.WithStateStore(store)
+
+		Expect(escalator.Start(alert)).To(BeTrue())
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		Expect(store.Acknowledge(alert.Fingerprint(), clock.Now())).To(Succeed())
+
+		clock.Increment(600 * time.Second)
+		Eventually(func() bool { return escalator.Escalating(alert.Fingerprint()) }).Should(BeFalse())
+		Expect(sender.Sent()).To(BeEmpty())
+	})
+
+	It("escalates an acknowledged alert again when it fires after being resolved", func() {
+		store := NewMemoryStateStore()
+		escalator.WithStateStore(store)
+
+		Expect(escalator.Start(alert)).To(BeTrue())
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		Expect(store.Acknowledge(alert.Fingerprint(), clock.Now())).To(Succeed())
+		clock.Increment(600 * time.Second)
+		Eventually(func() bool { return escalator.Escalating(alert.Fingerprint()) }).Should(BeFalse())
+
+		escalator.Resolve(alert.Fingerprint())
+		Expect(store.Acknowledged(alert.Fingerprint())).To(BeFalse())
+
+		Expect(escalator.Start(alert)).To(BeTrue())
+		Eventually(clock.WatcherCount).Should(Equal(1))
+		clock.Increment(600 * time.Second)
+		Eventually(sender.Sent).Should(Equal([]Destination{opsSpace}))
+	})
+
+	It("stops escalating once the alert is resolved", func() {
+		Expect(escalator.Start(alert)).To(BeTrue())
+		Eventually(clock.WatcherCount).Should(Equal(1))
+
+		Expect(escalator.Resolve(alert.Fingerprint())).To(BeTrue())
+		clock.Increment(time.Hour)
+		Consistently(sender.Sent).Should(BeEmpty())
+		Expect(escalator.Resolve(alert.Fingerprint())).To(BeFalse())
+	})
+
+	It("stops every escalation and starts no more once stopped", func() {
+		Expect(escalator.Start(alert)).To(BeTrue())
+		Eventually(clock.WatcherCount).Should(Equal(1))
+
+		escalator.Stop()
+		Expect(escalator.Escalating(alert.Fingerprint())).To(BeFalse())
+		Expect(escalator.Start(alert)).To(BeFalse())
+		clock.Increment(time.Hour)
+		Consistently(sender.Sent).Should(BeEmpty())
+	})
+
+	It("does not escalate the same alert twice", func() {
+		Expect(escalator.Start(alert)).To(BeTrue())
+		Expect(escalator.Start(alert)).To(BeFalse())
+	})
+
+	It("does not escalate alerts no policy matches", func() {
+		Expect(escalator.Start(Alert{Product: "redis", Severity: SeverityWarning})).To(BeFalse())
+	})
+
+	Context("when the alert is dispatched to several receivers and one of them fails", func() {
+		var (
+			server     *httptest.Server
+			dispatcher *Dispatcher
+		)
+
+		BeforeEach(func() {
+			server = fakeCloudFoundryServer("on-call")
+			config.CloudController.URL = server.URL
+			config.Notifications.ServiceURL = server.URL
+			config.Route = Route{Receivers: []string{"ops", "on-call"}}
+			config.GlobalTimeoutSeconds = 1
+		})
+
+		JustBeforeEach(func() {
+			var err error
+			dispatcher, err = NewDispatcher(New(config, log.New(GinkgoWriter, "", 0)))
+			Expect(err).NotTo(HaveOccurred())
+			dispatcher.WithEscalator(escalator)
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("starts escalating", func() {
+			Expect(dispatcher.Dispatch(alert)).To(BeAssignableToTypeOf(ReceiverErrors{}))
+			Expect(escalator.Escalating(alert.Fingerprint())).To(BeTrue())
+		})
+	})
+
+	Context("with silences", func() {
+		var (
+			dir   string
+			store *SilenceStore
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "escalation")
+			Expect(err).NotTo(HaveOccurred())
+			store = NewSilenceStore(filepath.Join(dir, "silences.json"))
+		})
+
+		JustBeforeEach(func() {
+			escalator.WithSilences(store)
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("stops escalating once the alert is silenced", func() {
+			Expect(escalator.Start(alert)).To(BeTrue())
+			Eventually(clock.WatcherCount).Should(Equal(1))
+
+			_, err := store.Create(Silence{Product: "redis", Action: SilenceActionDrop, StartsAt: clock.Now(), EndsAt: clock.Now().Add(2 * time.Hour)})
+			Expect(err).NotTo(HaveOccurred())
+			clock.Increment(time.Hour)
+			Eventually(func() bool { return escalator.Escalating(alert.Fingerprint()) }).Should(BeFalse())
+			Expect(sender.Sent()).To(BeEmpty())
+		})
+	})
+
+	Context("when the alert is only collected into a digest", func() {
+		It("does not start escalating", func() {
+			config.EscalationPolicies[0].Match = RouteMatch{}
+			client := New(config, log.New(GinkgoWriter, "", 0))
+			digest, err := NewDigestAggregator(Digest{Severity: "critical"}, &fakeDigestSender{}, clock, log.New(GinkgoWriter, "", 0))
+			Expect(err).NotTo(HaveOccurred())
+			dispatcher, err := NewDispatcher(client)
+			Expect(err).NotTo(HaveOccurred())
+			dispatcher.WithDigest(digest).WithEscalator(escalator)
+
+			warning := Alert{Product: "redis", Subject: "slow", Severity: SeverityWarning}
+			Expect(dispatcher.Dispatch(warning)).To(Succeed())
+			Expect(digest.Pending()).To(Equal(1))
+			Expect(escalator.Escalating(warning.Fingerprint())).To(BeFalse())
+		})
+	})
+
+	Context("when a step names an unknown receiver", func() {
+		It("fails", func() {
+			config.EscalationPolicies[0].Steps[0].Receiver = "pager"
+			_, err := NewEscalator(config, sender, clock, log.New(GinkgoWriter, "", 0))
+			Expect(err).To(MatchError("invalid escalation config: unknown receiver: 'pager'"))
+		})
+	})
+})
@@ -0,0 +1,136 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+// fakeClock only moves when Increment is called. The vendored clock package
+// does not include code.cloudfoundry.org/clock/fakeclock.
+type fakeClock struct {
+	mutex    sync.Mutex
+	now      time.Time
+	watchers []*fakeTimer
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	<-c.NewTimer(d).C()
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) clock.Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	timer := &fakeTimer{clock: c, fireAt: c.now.Add(d), channel: make(chan time.Time, 1)}
+	c.watchers = append(c.watchers, timer)
+	return timer
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) clock.Ticker {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	timer := &fakeTimer{clock: c, fireAt: c.now.Add(d), interval: d, channel: make(chan time.Time, 1)}
+	c.watchers = append(c.watchers, timer)
+	return fakeTicker{timer}
+}
+
+// WatcherCount returns the number of timers and tickers waiting to fire, so
+// tests can wait for a goroutine to start waiting before moving the clock
+func (c *fakeClock) WatcherCount() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.watchers)
+}
+
+func (c *fakeClock) Increment(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+	var waiting []*fakeTimer
+	for _, timer := range c.watchers {
+		if timer.fireAt.After(c.now) {
+			waiting = append(waiting, timer)
+			continue
+		}
+
+		select {
+		case timer.channel <- c.now:
+		default:
+		}
+		if timer.interval > 0 {
+			timer.fireAt = c.now.Add(timer.interval)
+			waiting = append(waiting, timer)
+		}
+	}
+	c.watchers = waiting
+}
+
+func (c *fakeClock) remove(timer *fakeTimer) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, watcher := range c.watchers {
+		if watcher == timer {
+			c.watchers = append(c.watchers[:i], c.watchers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock    *fakeClock
+	fireAt   time.Time
+	interval time.Duration
+	channel  chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.channel
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.clock.remove(t)
+
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	t.fireAt = t.clock.now.Add(d)
+	t.clock.watchers = append(t.clock.watchers, t)
+	return active
+}
+
+func (t *fakeTimer) Stop() bool {
+	return t.clock.remove(t)
+}
+
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
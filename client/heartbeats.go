@@ -0,0 +1,400 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+const (
+	defaultHeartbeatCheckInterval = 30 * time.Second
+	defaultHeartbeatProduct       = "heartbeat"
+)
+
+type AlertDispatcher interface {
+	Dispatch(alert Alert) error
+}
+
+// Heartbeat is a job that is expected to ping at least once every interval.
+// Missed is set once an alert has been sent for the missed deadline, so that
+// the next ping can resolve it.
+type Heartbeat struct {
+	Name            string    `json:"name"`
+	Product         string    `json:"product,omitempty"`
+	ServiceInstance string    `json:"service_instance,omitempty"`
+	Severity        Severity  `json:"severity"`
+	IntervalSeconds int       `json:"interval_seconds"`
+	GraceSeconds    int       `json:"grace_seconds,omitempty"`
+	LastPing        time.Time `json:"last_ping"`
+	Missed          bool      `json:"missed,omitempty"`
+}
+
+func (h Heartbeat) Validate() error {
+	if h.Name == "" || strings.Contains(h.Name, "/") {
+		return errors.New("invalid heartbeat: name must be set and must not contain '/'")
+	}
+	if h.IntervalSeconds <= 0 {
+		return errors.New("invalid heartbeat: interval must be set")
+	}
+	if h.GraceSeconds < 0 {
+		return errors.New("invalid heartbeat: grace must not be negative")
+	}
+	return nil
+}
+
+func (h Heartbeat) Deadline() time.Time {
+	return h.LastPing.Add(time.Duration(h.IntervalSeconds+h.GraceSeconds) * time.Second)
+}
+
+func (h Heartbeat) alert(resolved bool, now time.Time) Alert {
+	product := h.Product
+	if product == "" {
+		product = defaultHeartbeatProduct
+	}
+
+	interval := time.Duration(h.IntervalSeconds) * time.Second
+	content := fmt.Sprintf("No heartbeat received from %s since %s. It is expected at least every %s.", h.Name, h.LastPing.Format(time.RFC3339), interval)
+	occurredAt := h.Deadline()
+	if resolved {
+		content = fmt.Sprintf("Heartbeat received from %s again at %s.", h.Name, now.Format(time.RFC3339))
+		occurredAt = now
+	}
+
+	return Alert{
+		Product:           product,
+		ServiceInstanceID: h.ServiceInstance,
+		Subject:           fmt.Sprintf("Heartbeat %s missed", h.Name),
+		Content:           content,
+		Severity:          h.Severity,
+		Labels:            map[string]string{"heartbeat": h.Name},
+		Resolved:          resolved,
+		OccurredAt:        occurredAt,
+	}
+}
+
+// HeartbeatPing is what a job sends. Settings left unset are kept from earlier
+// pings, so jobs only need to declare their interval on the first one.
+type HeartbeatPing struct {
+	Name            string
+	Product         string
+	ServiceInstance string
+	Severity        *Severity
+	Interval        time.Duration
+	Grace           time.Duration
+}
+
+type heartbeatsFile struct {
+	Heartbeats map[string]Heartbeat `json:"heartbeats"`
+}
+
+// HeartbeatMonitor keeps heartbeats in a local JSON file and sends an alert
+// for each heartbeat that misses its deadline, then resolves it on the next ping
+type HeartbeatMonitor struct {
+	path       string
+	interval   time.Duration
+	dispatcher AlertDispatcher
+	clock      clock.Clock
+	logger     *log.Logger
+	mutex      sync.Mutex
+}
+
+func NewHeartbeatMonitor(config Heartbeats, dispatcher AlertDispatcher, clock clock.Clock, logger *log.Logger) (*HeartbeatMonitor, error) {
+	if config.File == "" {
+		return nil, ConfigError{Section: "heartbeats", Err: errors.New("file must be set")}
+	}
+	if config.CheckIntervalSeconds < 0 {
+		return nil, ConfigError{Section: "heartbeats", Err: errors.New("check_interval_seconds must not be negative")}
+	}
+
+	monitor := &HeartbeatMonitor{
+		path:       config.File,
+		interval:   defaultHeartbeatCheckInterval,
+		dispatcher: dispatcher,
+		clock:      clock,
+		logger:     logger,
+	}
+	if config.CheckIntervalSeconds != 0 {
+		monitor.interval = time.Duration(config.CheckIntervalSeconds) * time.Second
+	}
+	return monitor, nil
+}
+
+// Ping records a heartbeat, registering it on the first ping. New heartbeats
+// are critical unless the ping sets a severity.
+func (m *HeartbeatMonitor) Ping(ping HeartbeatPing) (Heartbeat, error) {
+	now := m.clock.Now()
+
+	var heartbeat Heartbeat
+	var wasMissed bool
+	err := m.update(func(contents *heartbeatsFile) error {
+		existing, ok := contents.Heartbeats[ping.Name]
+		heartbeat = existing
+		if !ok {
+			heartbeat.Severity = SeverityCritical
+		}
+		wasMissed = heartbeat.Missed
+
+		heartbeat.Name = ping.Name
+		if ping.Product != "" {
+			heartbeat.Product = ping.Product
+		}
+		if ping.ServiceInstance != "" {
+			heartbeat.ServiceInstance = ping.ServiceInstance
+		}
+		if ping.Severity != nil {
+			heartbeat.Severity = *ping.Severity
+		}
+		if ping.Interval != 0 {
+			heartbeat.IntervalSeconds = int(ping.Interval / time.Second)
+		}
+		if ping.Grace != 0 {
+			heartbeat.GraceSeconds = int(ping.Grace / time.Second)
+		}
+		heartbeat.LastPing = now
+
+		if err := heartbeat.Validate(); err != nil {
+			return err
+		}
+		contents.Heartbeats[heartbeat.Name] = heartbeat
+		return nil
+	})
+	if err != nil {
+		return Heartbeat{}, err
+	}
+
+	// the heartbeat stays missed until the resolution has been sent, so that
+	// the next ping tries again
+	if wasMissed {
+		m.logger.Printf("Heartbeat %s received again", heartbeat.Name)
+		if err := m.dispatcher.Dispatch(heartbeat.alert(true, now)); err != nil {
+			m.logger.Printf("Failed to resolve missed heartbeat %s: %s", heartbeat.Name, err)
+			return heartbeat, nil
+		}
+		if err := m.markResolved(heartbeat.Name); err != nil {
+			return Heartbeat{}, err
+		}
+		heartbeat.Missed = false
+	}
+	return heartbeat, nil
+}
+
+func (m *HeartbeatMonitor) List() ([]Heartbeat, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	contents, err := m.read()
+	if err != nil {
+		return nil, err
+	}
+
+	var heartbeats []Heartbeat
+	for _, heartbeat := range contents.Heartbeats {
+		heartbeats = append(heartbeats, heartbeat)
+	}
+	sort.Slice(heartbeats, func(i, j int) bool { return heartbeats[i].Name < heartbeats[j].Name })
+	return heartbeats, nil
+}
+
+func (m *HeartbeatMonitor) Remove(name string) error {
+	return m.update(func(contents *heartbeatsFile) error {
+		if _, ok := contents.Heartbeats[name]; !ok {
+			return fmt.Errorf("heartbeat not found: '%s'", name)
+		}
+		delete(contents.Heartbeats, name)
+		return nil
+	})
+}
+
+// Check sends an alert for every heartbeat past its deadline that has not been
+// alerted on yet. Heartbeats whose alert fails to send are tried again on the
+// next check.
+func (m *HeartbeatMonitor) Check() error {
+	now := m.clock.Now()
+	heartbeats, err := m.List()
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, heartbeat := range heartbeats {
+		if heartbeat.Missed || now.Before(heartbeat.Deadline()) {
+			continue
+		}
+
+		m.logger.Printf("Heartbeat %s missed its deadline of %s", heartbeat.Name, heartbeat.Deadline().Format(time.RFC3339))
+		if err := m.dispatcher.Dispatch(heartbeat.alert(false, now)); err != nil {
+			m.logger.Printf("Failed to send alert for missed heartbeat %s: %s", heartbeat.Name, err)
+			lastErr = err
+			continue
+		}
+		if err := m.markMissed(heartbeat); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Run checks the heartbeats every check interval until stop is closed
+func (m *HeartbeatMonitor) Run(stop <-chan struct{}) {
+	ticker := m.clock.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			if err := m.Check(); err != nil {
+				m.logger.Printf("Failed to check heartbeats: %s", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// markMissed only marks the heartbeat when it has not been pinged since it
+// was checked, otherwise the ping would be lost
+func (m *HeartbeatMonitor) markMissed(checked Heartbeat) error {
+	return m.update(func(contents *heartbeatsFile) error {
+		current, ok := contents.Heartbeats[checked.Name]
+		if ok && current.LastPing.Equal(checked.LastPing) {
+			current.Missed = true
+			contents.Heartbeats[checked.Name] = current
+		}
+		return nil
+	})
+}
+
+func (m *HeartbeatMonitor) markResolved(name string) error {
+	return m.update(func(contents *heartbeatsFile) error {
+		if current, ok := contents.Heartbeats[name]; ok {
+			current.Missed = false
+			contents.Heartbeats[name] = current
+		}
+		return nil
+	})
+}
+
+func (m *HeartbeatMonitor) update(change func(contents *heartbeatsFile) error) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	unlock, err := lockFile(m.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	contents, err := m.read()
+	if err != nil {
+		return err
+	}
+	if err := change(&contents); err != nil {
+		return err
+	}
+	return writeJSONFile(m.path, contents)
+}
+
+func (m *HeartbeatMonitor) read() (heartbeatsFile, error) {
+	var contents heartbeatsFile
+	err := readJSONFile(m.path, &contents)
+	if contents.Heartbeats == nil {
+		contents.Heartbeats = map[string]Heartbeat{}
+	}
+	return contents, err
+}
+
+type heartbeatResponse struct {
+	Heartbeat  *Heartbeat  `json:"heartbeat,omitempty"`
+	Heartbeats []Heartbeat `json:"heartbeats,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// NewHeartbeatsHandler serves the heartbeats API below /heartbeats/. Jobs ping
+// with POST /heartbeats/<name>?interval=1h, optionally with grace, product,
+// service_instance and severity. GET lists heartbeats and DELETE removes one.
+func NewHeartbeatsHandler(monitor *HeartbeatMonitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		name := strings.TrimPrefix(req.URL.Path, "/heartbeats")
+		name = strings.TrimPrefix(name, "/")
+
+		switch {
+		case req.Method == "GET" && name == "":
+			heartbeats, err := monitor.List()
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, heartbeatResponse{Error: err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, heartbeatResponse{Heartbeats: heartbeats})
+		case req.Method == "POST" && name != "":
+			ping, err := heartbeatFromQuery(name, req)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, heartbeatResponse{Error: err.Error()})
+				return
+			}
+			heartbeat, err := monitor.Ping(ping)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, heartbeatResponse{Error: err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusOK, heartbeatResponse{Heartbeat: &heartbeat})
+		case req.Method == "DELETE" && name != "":
+			if err := monitor.Remove(name); err != nil {
+				writeJSON(w, http.StatusNotFound, heartbeatResponse{Error: err.Error()})
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeJSON(w, http.StatusMethodNotAllowed, heartbeatResponse{Error: "method not allowed"})
+		}
+	})
+}
+
+func heartbeatFromQuery(name string, req *http.Request) (HeartbeatPing, error) {
+	query := req.URL.Query()
+	ping := HeartbeatPing{
+		Name:            name,
+		Product:         query.Get("product"),
+		ServiceInstance: query.Get("service_instance"),
+	}
+
+	durations := []struct {
+		param  string
+		target *time.Duration
+	}{
+		{"interval", &ping.Interval},
+		{"grace", &ping.Grace},
+	}
+	for _, d := range durations {
+		value := query.Get(d.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return HeartbeatPing{}, fmt.Errorf("invalid %s: %s", d.param, err)
+		}
+		*d.target = parsed
+	}
+
+	if severity := query.Get("severity"); severity != "" {
+		parsed, err := ParseSeverity(severity)
+		if err != nil {
+			return HeartbeatPing{}, err
+		}
+		ping.Severity = &parsed
+	}
+	return ping, nil
+}
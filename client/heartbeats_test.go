@@ -0,0 +1,206 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeAlertDispatcher struct {
+	dispatched []Alert
+	err        error
+}
+
+func (f *fakeAlertDispatcher) Dispatch(alert Alert) error {
+	f.dispatched = append(f.dispatched, alert)
+	return f.err
+}
+
+var _ = Describe("HeartbeatMonitor", func() {
+	var (
+		dir        string
+		dispatcher *fakeAlertDispatcher
+		clock      *fakeClock
+		monitor    *HeartbeatMonitor
+		logger     = log.New(GinkgoWriter, "", 0)
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "heartbeats")
+		Expect(err).NotTo(HaveOccurred())
+
+		dispatcher = &fakeAlertDispatcher{}
+		clock = newFakeClock(time.Date(2016, 10, 1, 0, 0, 0, 0, time.UTC))
+		monitor, err = NewHeartbeatMonitor(Heartbeats{File: filepath.Join(dir, "heartbeats.json")}, dispatcher, clock, logger)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("requires a file", func() {
+		_, err := NewHeartbeatMonitor(Heartbeats{}, dispatcher, clock, logger)
+		Expect(err).To(MatchError("invalid heartbeats config: file must be set"))
+	})
+
+	It("requires an interval on the first ping", func() {
+		_, err := monitor.Ping(HeartbeatPing{Name: "backup"})
+		Expect(err).To(MatchError("invalid heartbeat: interval must be set"))
+	})
+
+	Context("when a heartbeat is registered", func() {
+		BeforeEach(func() {
+			_, err := monitor.Ping(HeartbeatPing{Name: "backup", Product: "mysql", Interval: time.Hour, Grace: 5 * time.Minute})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("does not alert before the deadline", func() {
+			clock.Increment(time.Hour + 4*time.Minute)
+			Expect(monitor.Check()).To(Succeed())
+			Expect(dispatcher.dispatched).To(BeEmpty())
+		})
+
+		It("alerts once when the deadline is missed", func() {
+			clock.Increment(time.Hour + 5*time.Minute)
+			Expect(monitor.Check()).To(Succeed())
+			Expect(monitor.Check()).To(Succeed())
+
+			Expect(dispatcher.dispatched).To(HaveLen(1))
+			alert := dispatcher.dispatched[0]
+			Expect(alert.Product).To(Equal("mysql"))
+			Expect(alert.Subject).To(Equal("Heartbeat backup missed"))
+			Expect(alert.Severity).To(Equal(SeverityCritical))
+			Expect(alert.Labels).To(Equal(map[string]string{"heartbeat": "backup"}))
+			Expect(alert.Resolved).To(BeFalse())
+			Expect(alert.OccurredAt).To(Equal(time.Date(2016, 10, 1, 1, 5, 0, 0, time.UTC)))
+			Expect(alert.Content).To(Equal("No heartbeat received from backup since 2016-10-01T00:00:00Z. It is expected at least every 1h0m0s."))
+		})
+
+		It("resolves the alert on the next ping", func() {
+			clock.Increment(2 * time.Hour)
+			Expect(monitor.Check()).To(Succeed())
+
+			heartbeat, err := monitor.Ping(HeartbeatPing{Name: "backup"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(heartbeat.Missed).To(BeFalse())
+			Expect(heartbeat.IntervalSeconds).To(Equal(3600))
+
+			Expect(dispatcher.dispatched).To(HaveLen(2))
+			Expect(dispatcher.dispatched[1].Resolved).To(BeTrue())
+			Expect(dispatcher.dispatched[1].Fingerprint()).To(Equal(dispatcher.dispatched[0].Fingerprint()))
+		})
+
+		It("tries to resolve the alert again on the next ping when the resolution fails to send", func() {
+			clock.Increment(2 * time.Hour)
+			Expect(monitor.Check()).To(Succeed())
+
+			dispatcher.err = errors.New("failed")
+			heartbeat, err := monitor.Ping(HeartbeatPing{Name: "backup"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(heartbeat.Missed).To(BeTrue())
+
+			dispatcher.err = nil
+			heartbeat, err = monitor.Ping(HeartbeatPing{Name: "backup"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(heartbeat.Missed).To(BeFalse())
+
+			Expect(dispatcher.dispatched).To(HaveLen(3))
+			Expect(dispatcher.dispatched[2].Resolved).To(BeTrue())
+			heartbeats, err := monitor.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(heartbeats[0].Missed).To(BeFalse())
+		})
+
+		It("tries again on the next check when the alert fails to send", func() {
+			dispatcher.err = errors.New("failed")
+			clock.Increment(2 * time.Hour)
+			Expect(monitor.Check()).To(MatchError("failed"))
+
+			dispatcher.err = nil
+			Expect(monitor.Check()).To(Succeed())
+			Expect(dispatcher.dispatched).To(HaveLen(2))
+		})
+
+		It("keeps heartbeats across restarts", func() {
+			restarted, err := NewHeartbeatMonitor(Heartbeats{File: filepath.Join(dir, "heartbeats.json")}, dispatcher, clock, logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			clock.Increment(2 * time.Hour)
+			Expect(restarted.Check()).To(Succeed())
+			Expect(dispatcher.dispatched).To(HaveLen(1))
+		})
+
+		It("stops alerting once removed", func() {
+			Expect(monitor.Remove("backup")).To(Succeed())
+			clock.Increment(2 * time.Hour)
+			Expect(monitor.Check()).To(Succeed())
+			Expect(dispatcher.dispatched).To(BeEmpty())
+		})
+	})
+
+	Describe("the HTTP handler", func() {
+		var handler http.Handler
+
+		BeforeEach(func() {
+			handler = NewHeartbeatsHandler(monitor)
+		})
+
+		It("registers heartbeats from query parameters", func() {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/heartbeats/backup?interval=1h&severity=warning&product=mysql", nil))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			heartbeats, err := monitor.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(heartbeats).To(HaveLen(1))
+			Expect(heartbeats[0].IntervalSeconds).To(Equal(3600))
+			Expect(heartbeats[0].Severity).To(Equal(SeverityWarning))
+			Expect(heartbeats[0].Product).To(Equal("mysql"))
+		})
+
+		It("lists heartbeats", func() {
+			_, err := monitor.Ping(HeartbeatPing{Name: "backup", Interval: time.Minute})
+			Expect(err).NotTo(HaveOccurred())
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/heartbeats", nil))
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			var response struct {
+				Heartbeats []Heartbeat `json:"heartbeats"`
+			}
+			Expect(json.NewDecoder(recorder.Body).Decode(&response)).To(Succeed())
+			Expect(response.Heartbeats).To(HaveLen(1))
+			Expect(response.Heartbeats[0].Name).To(Equal("backup"))
+		})
+
+		It("rejects invalid intervals", func() {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/heartbeats/backup?interval=often", nil))
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("returns 404 when removing an unknown heartbeat", func() {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/heartbeats/unknown", nil))
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
@@ -0,0 +1,140 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"sort"
+	"strings"
+)
+
+const DefaultLocale = "en"
+
+// Messages is the fixed text of the built-in email templates in one
+// language. Templates refer to it as .Text, for example {{.Text.AlertFrom}}.
+// DigestOf and DigestSubject are format strings taking the number of alerts.
+type Messages struct {
+	AlertFrom       string
+	ServiceInstance string
+	Acknowledge     string
+	AlertOccurredAt string
+	SentAt          string
+	AlertSentAt     string
+	Resolved        string
+	DigestOf        string
+	AlertsFrom      string
+	OccurredAt      string
+	DigestSentAt    string
+	DigestSubject   string
+}
+
+// Locale is a message catalog and the name of its language
+type Locale struct {
+	Code     string
+	Name     string
+	Messages Messages
+}
+
+var locales = map[string]Locale{
+	"en": {Code: "en", Name: "English", Messages: Messages{
+		AlertFrom:       "Alert from",
+		ServiceInstance: "service instance",
+		Acknowledge:     "Acknowledge this alert",
+		AlertOccurredAt: "Alert occurred at",
+		SentAt:          "sent at",
+		AlertSentAt:     "Alert sent at",
+		Resolved:        "Resolved",
+		DigestOf:        "Digest of %d alerts",
+		AlertsFrom:      "Alerts from",
+		OccurredAt:      "occurred at",
+		DigestSentAt:    "Digest sent at",
+		DigestSubject:   "%d alerts",
+	}},
+	"de": {Code: "de", Name: "Deutsch", Messages: Messages{
+		AlertFrom:       "Alarm von",
+		ServiceInstance: "Service-Instanz",
+		Acknowledge:     "Diesen Alarm bestätigen",
+		AlertOccurredAt: "Alarm aufgetreten am",
+		SentAt:          "gesendet am",
+		AlertSentAt:     "Alarm gesendet am",
+		Resolved:        "Behoben",
+		DigestOf:        "Zusammenfassung von %d Alarmen",
+		AlertsFrom:      "Alarme von",
+		OccurredAt:      "aufgetreten am",
+		DigestSentAt:    "Zusammenfassung gesendet am",
+		DigestSubject:   "%d Alarme",
+	}},
+	"es": {Code: "es", Name: "Español", Messages: Messages{
+		AlertFrom:       "Alerta de",
+		ServiceInstance: "instancia de servicio",
+		Acknowledge:     "Confirmar esta alerta",
+		AlertOccurredAt: "Alerta ocurrida el",
+		SentAt:          "enviada el",
+		AlertSentAt:     "Alerta enviada el",
+		Resolved:        "Resuelta",
+		DigestOf:        "Resumen de %d alertas",
+		AlertsFrom:      "Alertas de",
+		OccurredAt:      "ocurrida el",
+		DigestSentAt:    "Resumen enviado el",
+		DigestSubject:   "%d alertas",
+	}},
+	"fr": {Code: "fr", Name: "Français", Messages: Messages{
+		AlertFrom:       "Alerte de",
+		ServiceInstance: "instance de service",
+		Acknowledge:     "Acquitter cette alerte",
+		AlertOccurredAt: "Alerte survenue le",
+		SentAt:          "envoyée le",
+		AlertSentAt:     "Alerte envoyée le",
+		Resolved:        "Résolue",
+		DigestOf:        "Résumé de %d alertes",
+		AlertsFrom:      "Alertes de",
+		OccurredAt:      "survenue le",
+		DigestSentAt:    "Résumé envoyé le",
+		DigestSubject:   "%d alertes",
+	}},
+	"ja": {Code: "ja", Name: "日本語", Messages: Messages{
+		AlertFrom:       "アラート送信元",
+		ServiceInstance: "サービスインスタンス",
+		Acknowledge:     "このアラートを確認する",
+		AlertOccurredAt: "アラート発生日時",
+		SentAt:          "送信日時",
+		AlertSentAt:     "アラート送信日時",
+		Resolved:        "解決済み",
+		DigestOf:        "%d 件のアラートのまとめ",
+		AlertsFrom:      "アラート送信元",
+		OccurredAt:      "発生日時",
+		DigestSentAt:    "まとめ送信日時",
+		DigestSubject:   "%d 件のアラート",
+	}},
+}
+
+// Locales lists the available message catalogs, sorted by code
+func Locales() []Locale {
+	var available []Locale
+	for _, locale := range locales {
+		available = append(available, locale)
+	}
+	sort.Slice(available, func(i, j int) bool {
+		return available[i].Code < available[j].Code
+	})
+	return available
+}
+
+// LookupLocale finds the catalog for a locale such as de, de-CH or de_CH,
+// trying the language alone when there is no catalog for the region. It
+// returns false, and the English catalog, when there is none at all.
+func LookupLocale(code string) (Locale, bool) {
+	code = strings.ToLower(strings.Replace(code, "_", "-", -1))
+	if locale, ok := locales[code]; ok {
+		return locale, true
+	}
+	if i := strings.Index(code, "-"); i > 0 {
+		if locale, ok := locales[code[:i]]; ok {
+			return locale, true
+		}
+	}
+	return locales[DefaultLocale], false
+}
@@ -0,0 +1,84 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"log"
+	"reflect"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Locales", func() {
+	It("lists the catalogs sorted by code", func() {
+		var codes []string
+		for _, locale := range Locales() {
+			codes = append(codes, locale.Code)
+		}
+		Expect(codes).To(Equal([]string{"de", "en", "es", "fr", "ja"}))
+	})
+
+	It("has every message in every catalog", func() {
+		for _, locale := range Locales() {
+			messages := reflect.ValueOf(locale.Messages)
+			for i := 0; i < messages.NumField(); i++ {
+				Expect(messages.Field(i).String()).NotTo(BeEmpty(), "%s is missing %s", locale.Code, messages.Type().Field(i).Name)
+			}
+		}
+	})
+
+	It("falls back from a region to its language", func() {
+		locale, ok := LookupLocale("de_CH")
+		Expect(ok).To(BeTrue())
+		Expect(locale.Code).To(Equal("de"))
+	})
+
+	It("falls back to English for unknown locales", func() {
+		locale, ok := LookupLocale("tlh")
+		Expect(ok).To(BeFalse())
+		Expect(locale.Code).To(Equal("en"))
+	})
+
+	It("translates the fixed text of the built-in templates", func() {
+		date := time.Date(2009, 11, 10, 23, 0, 1, 0, time.UTC)
+		alert := Alert{Product: "redis", ServiceInstanceID: "instance", Content: "down", OccurredAt: date}
+
+		email, err := defaultEmailTemplates.Render(alert, "fr", "https://alerts.example.com/ack", date)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(email.Text).To(Equal(`Alerte de redis, instance de service instance:
+
+down
+
+Acquitter cette alerte: https://alerts.example.com/ack
+
+[Alerte survenue le 2009-11-10T23:00:01Z, envoyée le 2009-11-10T23:00:01Z]`))
+
+		digest, err := templateDigestBody([]Alert{alert}, "de", date, defaultTimestampFormat)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(digest).To(HavePrefix("Zusammenfassung von 1 Alarmen\n\nAlarme von redis, Service-Instanz instance:"))
+	})
+
+	It("translates the digest subject after its English prefix, by the locale of the destination", func() {
+		config := Config{
+			Notifications: Notifications{CFOrg: "org", CFSpace: "space", Locale: "de"},
+			Receivers:     []Receiver{{Name: "ops", Destination: Destination{CFOrg: "org", CFSpace: "ops"}, Locale: "ja"}},
+		}
+		alertsClient := New(config, log.New(GinkgoWriter, "", 0))
+		alerts := []Alert{{Product: "redis", Subject: "disk full"}, {Product: "redis", Subject: "slow"}}
+
+		notification, err := alertsClient.createDigestNotification(Destination{CFOrg: "org", CFSpace: "space"}, alerts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notification.Subject).To(Equal("[Service Alert Digest] 2 Alarme"))
+		Expect(notification.Text).To(HavePrefix("Zusammenfassung von 2 Alarmen"))
+
+		notification, err = alertsClient.createDigestNotification(Destination{CFOrg: "org", CFSpace: "ops"}, alerts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(notification.Subject).To(Equal("[Service Alert Digest] 2 件のアラート"))
+	})
+})
@@ -0,0 +1,162 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const markdownCodeFence = "```"
+
+var (
+	markdownHeading  = regexp.MustCompile(`^(#{1,3})\s+(.*)$`)
+	markdownBullet   = regexp.MustCompile(`^[-*]\s+(.*)$`)
+	markdownNumbered = regexp.MustCompile(`^\d+\.\s+(.*)$`)
+	markdownQuote    = regexp.MustCompile(`^>\s?(.*)$`)
+	markdownLink     = regexp.MustCompile(`\[([^\]]+)\]\(((?:https?://|mailto:)[^)\s]+)\)`)
+	markdownStrong   = regexp.MustCompile(`\*\*([^*<]+)\*\*`)
+	markdownEmphasis = regexp.MustCompile(`\*([^*\s<](?:[^*<]*[^*\s<])?)\*`)
+)
+
+// renderMarkdown turns a safe subset of Markdown into HTML: headings, bullet
+// and numbered lists, quotes, fenced code blocks, inline code, bold, italics
+// and links to http, https and mailto URLs. Everything else, including raw
+// HTML, is escaped, so the output is safe to embed whatever the input. Line
+// breaks inside paragraphs are kept, as alert content is often log output.
+func renderMarkdown(text string) string {
+	var out bytes.Buffer
+	var paragraph, quote []string
+	var list string
+	var listItems []string
+
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + strings.Join(paragraph, "<br>\n") + "</p>\n")
+			paragraph = nil
+		}
+	}
+	flushQuote := func() {
+		if len(quote) > 0 {
+			out.WriteString("<blockquote><p>" + strings.Join(quote, "<br>\n") + "</p></blockquote>\n")
+			quote = nil
+		}
+	}
+	flushList := func() {
+		if len(listItems) > 0 {
+			out.WriteString("<" + list + ">\n")
+			for _, item := range listItems {
+				out.WriteString("<li>" + item + "</li>\n")
+			}
+			out.WriteString("</" + list + ">\n")
+			list, listItems = "", nil
+		}
+	}
+	flush := func() {
+		flushParagraph()
+		flushQuote()
+		flushList()
+	}
+	addListItem := func(kind, item string) {
+		if list != kind {
+			flush()
+			list = kind
+		}
+		listItems = append(listItems, renderInlineMarkdown(item))
+	}
+
+	lines := strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, markdownCodeFence) {
+			flush()
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), markdownCodeFence); i++ {
+				code = append(code, html.EscapeString(lines[i]))
+			}
+			out.WriteString("<pre><code>" + strings.Join(code, "\n") + "</code></pre>\n")
+			continue
+		}
+
+		if trimmed == "" {
+			flush()
+			continue
+		}
+
+		if groups := markdownHeading.FindStringSubmatch(trimmed); groups != nil {
+			flush()
+			level := strconv.Itoa(len(groups[1]))
+			out.WriteString("<h" + level + ">" + renderInlineMarkdown(groups[2]) + "</h" + level + ">\n")
+			continue
+		}
+		if groups := markdownBullet.FindStringSubmatch(trimmed); groups != nil {
+			addListItem("ul", groups[1])
+			continue
+		}
+		if groups := markdownNumbered.FindStringSubmatch(trimmed); groups != nil {
+			addListItem("ol", groups[1])
+			continue
+		}
+		if groups := markdownQuote.FindStringSubmatch(trimmed); groups != nil {
+			flushParagraph()
+			flushList()
+			quote = append(quote, renderInlineMarkdown(groups[1]))
+			continue
+		}
+
+		flushQuote()
+		flushList()
+		paragraph = append(paragraph, renderInlineMarkdown(line))
+	}
+	flush()
+
+	return out.String()
+}
+
+// renderInlineMarkdown renders code spans, links, bold and italics within a
+// line. Code spans and links are split out first so that emphasis markers in
+// them are left alone.
+func renderInlineMarkdown(text string) string {
+	var out bytes.Buffer
+	parts := strings.Split(text, "`")
+	for i, part := range parts {
+		switch {
+		case i%2 == 1 && i < len(parts)-1:
+			out.WriteString("<code>" + html.EscapeString(part) + "</code>")
+		case i%2 == 1:
+			out.WriteString(renderLinks("`" + part))
+		default:
+			out.WriteString(renderLinks(part))
+		}
+	}
+	return out.String()
+}
+
+func renderLinks(text string) string {
+	var out bytes.Buffer
+	last := 0
+	for _, match := range markdownLink.FindAllStringSubmatchIndex(text, -1) {
+		out.WriteString(renderEmphasis(text[last:match[0]]))
+		out.WriteString(`<a href="` + html.EscapeString(text[match[4]:match[5]]) + `">`)
+		out.WriteString(renderEmphasis(text[match[2]:match[3]]))
+		out.WriteString("</a>")
+		last = match[1]
+	}
+	out.WriteString(renderEmphasis(text[last:]))
+	return out.String()
+}
+
+func renderEmphasis(text string) string {
+	escaped := html.EscapeString(text)
+	escaped = markdownStrong.ReplaceAllString(escaped, "<strong>$1</strong>")
+	return markdownEmphasis.ReplaceAllString(escaped, "<em>$1</em>")
+}
@@ -28,15 +28,44 @@ type CFMetadata struct {
 	GUID string `json:"guid"`
 }
 
+type CFUsersResponse struct {
+	NextURL   string           `json:"next_url"`
+	Resources []CFUserResource `json:"resources"`
+}
+
+type CFUserResource struct {
+	Metadata CFMetadata   `json:"metadata"`
+	Entity   CFUserEntity `json:"entity"`
+}
+
+type CFUserEntity struct {
+	Username string `json:"username"`
+}
+
+type UAAUserResponse struct {
+	ID       string     `json:"id"`
+	UserName string     `json:"userName"`
+	Emails   []UAAEmail `json:"emails"`
+	Verified bool       `json:"verified"`
+}
+
+type UAAEmail struct {
+	Value   string `json:"value"`
+	Primary bool   `json:"primary"`
+}
+
 type SpaceNotificationRequest struct {
 	KindID  string `json:"kind_id"`
 	Subject string `json:"subject"`
 	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
 	ReplyTo string `json:"reply_to,omitempty"`
 }
 
 type UAATokenResponse struct {
-	Token string `json:"access_token"`
+	Token     string `json:"access_token"`
+	ExpiresIn int    `json:"expires_in"`
+	Scope     string `json:"scope"`
 }
 
 type CFInfoResponse struct {
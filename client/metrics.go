@@ -0,0 +1,257 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// MetricSends counts alerts and digests by backend and outcome
+	MetricSends = "service_alerts_sends_total"
+	// MetricRetries counts failed requests that were retried, by label: "CF
+	// INFO", "UAA", "CF API" or "CF Notifications"
+	MetricRetries = "service_alerts_retries_total"
+	// MetricSendDuration is how long sending took, by backend and outcome,
+	// including retries
+	MetricSendDuration = "service_alerts_send_duration_seconds"
+	// MetricQueueDepth is the number of alerts waiting, by queue: "digest"
+	// for alerts collected into digests, and "in_flight" for alerts the
+	// gateway is sending
+	MetricQueueDepth = "service_alerts_queue_depth"
+)
+
+// BackendCFNotifications is the backend label of sends through the CF
+// notifications service, currently the only backend
+const BackendCFNotifications = "cf_notifications"
+
+// Outcomes of sends, as the outcome label of MetricSends and
+// MetricSendDuration
+const (
+	OutcomeSent         = "sent"
+	OutcomeNoRecipients = "no_recipients"
+	OutcomeFailed       = "failed"
+	OutcomeTimedOut     = "timed_out"
+	OutcomeDryRun       = "dry_run"
+	OutcomeDropped      = "dropped"
+	OutcomeDeferred     = "deferred"
+)
+
+// Labels are the label names and values of a single time series
+type Labels map[string]string
+
+// Metrics receives the counts, timings and queue depths of the client. Set
+// one with WithMetrics; by default they are discarded.
+type Metrics interface {
+	IncCounter(name string, labels Labels)
+	ObserveHistogram(name string, labels Labels, value float64)
+	SetGauge(name string, labels Labels, value float64)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) IncCounter(string, Labels)                {}
+func (noopMetrics) ObserveHistogram(string, Labels, float64) {}
+func (noopMetrics) SetGauge(string, Labels, float64)         {}
+
+// WithMetrics sets where sends, retries and their durations are recorded
+func (c *ServiceAlertsClient) WithMetrics(metrics Metrics) *ServiceAlertsClient {
+	c.metrics = metrics
+	c.httpClient.metrics = metrics
+	return c
+}
+
+// DefaultHistogramBuckets are the upper bounds of the buckets of histograms
+// in seconds, up to the default timeout_seconds and beyond
+var DefaultHistogramBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120}
+
+var metricHelp = map[string]string{
+	MetricSends:        "Alerts and digests by backend and outcome.",
+	MetricRetries:      "Failed requests that were retried, by request label.",
+	MetricSendDuration: "Time taken to send alerts and digests in seconds, including retries.",
+	MetricQueueDepth:   "Alerts waiting to be sent, by queue.",
+}
+
+const (
+	counterType   = "counter"
+	gaugeType     = "gauge"
+	histogramType = "histogram"
+)
+
+// PrometheusMetrics keeps metrics in memory and serves them in the Prometheus
+// text format
+type PrometheusMetrics struct {
+	buckets []float64
+
+	mutex    sync.Mutex
+	families map[string]*metricFamily
+}
+
+type metricFamily struct {
+	metricType string
+	series     map[string]*series
+}
+
+type series struct {
+	labels  Labels
+	value   float64
+	count   uint64
+	buckets []uint64
+}
+
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{buckets: DefaultHistogramBuckets, families: map[string]*metricFamily{}}
+}
+
+func (m *PrometheusMetrics) IncCounter(name string, labels Labels) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.series(name, counterType, labels).value++
+}
+
+func (m *PrometheusMetrics) ObserveHistogram(name string, labels Labels, value float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	s := m.series(name, histogramType, labels)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(m.buckets))
+	}
+	for i, upperBound := range m.buckets {
+		if value <= upperBound {
+			s.buckets[i]++
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+func (m *PrometheusMetrics) SetGauge(name string, labels Labels, value float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.series(name, gaugeType, labels).value = value
+}
+
+// series finds or creates a time series. A name used for metrics of
+// different types keeps the type it was first used with, as Prometheus
+// allows only one.
+func (m *PrometheusMetrics) series(name, metricType string, labels Labels) *series {
+	family, ok := m.families[name]
+	if !ok {
+		family = &metricFamily{metricType: metricType, series: map[string]*series{}}
+		m.families[name] = family
+	}
+	if family.metricType != metricType {
+		return &series{}
+	}
+
+	key := formatLabels(labels)
+	s, ok := family.series[key]
+	if !ok {
+		copied := Labels{}
+		for name, value := range labels {
+			copied[name] = value
+		}
+		s = &series{labels: copied}
+		family.series[key] = s
+	}
+	return s
+}
+
+// WriteTo writes all metrics in the Prometheus text format, sorted by name
+// and labels so that the output is stable
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var out strings.Builder
+	for _, name := range m.sortedNames() {
+		family := m.families[name]
+		if help, ok := metricHelp[name]; ok {
+			fmt.Fprintf(&out, "# HELP %s %s\n", name, help)
+		}
+		fmt.Fprintf(&out, "# TYPE %s %s\n", name, family.metricType)
+
+		for _, key := range family.sortedKeys() {
+			s := family.series[key]
+			if family.metricType != histogramType {
+				fmt.Fprintf(&out, "%s%s %s\n", name, key, formatValue(s.value))
+				continue
+			}
+			for i, upperBound := range m.buckets {
+				fmt.Fprintf(&out, "%s_bucket%s %d\n", name, formatLabels(withLabel(s.labels, "le", formatValue(upperBound))), s.buckets[i])
+			}
+			fmt.Fprintf(&out, "%s_bucket%s %d\n", name, formatLabels(withLabel(s.labels, "le", "+Inf")), s.count)
+			fmt.Fprintf(&out, "%s_sum%s %s\n", name, key, formatValue(s.value))
+			fmt.Fprintf(&out, "%s_count%s %d\n", name, key, s.count)
+		}
+	}
+
+	written, err := io.WriteString(w, out.String())
+	return int64(written), err
+}
+
+// ServeHTTP serves the metrics for Prometheus to scrape
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+func withLabel(labels Labels, name, value string) Labels {
+	extended := Labels{name: value}
+	for labelName, labelValue := range labels {
+		extended[labelName] = labelValue
+	}
+	return extended
+}
+
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(labels[name])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func (m *PrometheusMetrics) sortedNames() []string {
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (f *metricFamily) sortedKeys() []string {
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
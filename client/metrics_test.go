@@ -0,0 +1,154 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/clock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("metrics", func() {
+	var metrics *PrometheusMetrics
+
+	BeforeEach(func() {
+		metrics = NewPrometheusMetrics()
+	})
+
+	exposition := func() string {
+		var out bytes.Buffer
+		_, err := metrics.WriteTo(&out)
+		Expect(err).NotTo(HaveOccurred())
+		return out.String()
+	}
+
+	Describe("PrometheusMetrics", func() {
+		It("writes counters and gauges in the Prometheus text format", func() {
+			metrics.IncCounter(MetricSends, Labels{"outcome": "sent", "backend": "cf_notifications"})
+			metrics.IncCounter(MetricSends, Labels{"outcome": "sent", "backend": "cf_notifications"})
+			metrics.IncCounter(MetricSends, Labels{"outcome": "failed", "backend": "cf_notifications"})
+			metrics.SetGauge(MetricQueueDepth, Labels{"queue": "digest"}, 3)
+			metrics.SetGauge(MetricQueueDepth, Labels{"queue": "digest"}, 2)
+
+			Expect(exposition()).To(Equal(`# HELP service_alerts_queue_depth Alerts waiting to be sent, by queue.
+# TYPE service_alerts_queue_depth gauge
+service_alerts_queue_depth{queue="digest"} 2
+# HELP service_alerts_sends_total Alerts and digests by backend and outcome.
+# TYPE service_alerts_sends_total counter
+service_alerts_sends_total{backend="cf_notifications",outcome="failed"} 1
+service_alerts_sends_total{backend="cf_notifications",outcome="sent"} 2
+`))
+		})
+
+		It("writes histograms with cumulative buckets", func() {
+			metrics.ObserveHistogram(MetricSendDuration, Labels{"outcome": "sent"}, 0.2)
+			metrics.ObserveHistogram(MetricSendDuration, Labels{"outcome": "sent"}, 3)
+			metrics.ObserveHistogram(MetricSendDuration, Labels{"outcome": "sent"}, 200)
+
+			Expect(exposition()).To(Equal(`# HELP service_alerts_send_duration_seconds Time taken to send alerts and digests in seconds, including retries.
+# TYPE service_alerts_send_duration_seconds histogram
+service_alerts_send_duration_seconds_bucket{le="0.1",outcome="sent"} 0
+service_alerts_send_duration_seconds_bucket{le="0.25",outcome="sent"} 1
+service_alerts_send_duration_seconds_bucket{le="0.5",outcome="sent"} 1
+service_alerts_send_duration_seconds_bucket{le="1",outcome="sent"} 1
+service_alerts_send_duration_seconds_bucket{le="2.5",outcome="sent"} 1
+service_alerts_send_duration_seconds_bucket{le="5",outcome="sent"} 2
+service_alerts_send_duration_seconds_bucket{le="10",outcome="sent"} 2
+service_alerts_send_duration_seconds_bucket{le="30",outcome="sent"} 2
+service_alerts_send_duration_seconds_bucket{le="60",outcome="sent"} 2
+service_alerts_send_duration_seconds_bucket{le="120",outcome="sent"} 2
+service_alerts_send_duration_seconds_bucket{le="+Inf",outcome="sent"} 3
+service_alerts_send_duration_seconds_sum{outcome="sent"} 203.2
+service_alerts_send_duration_seconds_count{outcome="sent"} 3
+`))
+		})
+
+		It("escapes label values and leaves out help for unknown metrics", func() {
+			metrics.IncCounter("custom_total", Labels{"path": `C:\alerts "new"` + "\n"})
+
+			Expect(exposition()).To(Equal(`# TYPE custom_total counter
+custom_total{path="C:\\alerts \"new\"\n"} 1
+`))
+		})
+
+		It("keeps the type a metric was first used with", func() {
+			metrics.IncCounter("custom_total", nil)
+			metrics.SetGauge("custom_total", nil, 10)
+
+			Expect(exposition()).To(Equal("# TYPE custom_total counter\ncustom_total 1\n"))
+		})
+
+		It("serves the metrics over HTTP", func() {
+			metrics.IncCounter(MetricRetries, Labels{"label": "UAA"})
+
+			recorder := httptest.NewRecorder()
+			metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("text/plain; version=0.0.4; charset=utf-8"))
+			Expect(recorder.Body.String()).To(ContainSubstring(`service_alerts_retries_total{label="UAA"} 1`))
+		})
+	})
+
+	Describe("instrumentation", func() {
+		It("counts retries by request label", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer server.Close()
+
+			httpClient := NewRetryHTTPClient(Config{GlobalTimeoutSeconds: 1}, log.New(GinkgoWriter, "", 0))
+			httpClient.metrics = metrics
+			req, err := http.NewRequest("GET", server.URL, nil)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = httpClient.doRequestWithRetries("CF API", req)
+			Expect(err).To(HaveOccurred())
+
+			Expect(exposition()).To(MatchRegexp(`service_alerts_retries_total\{label="CF API"\} [1-9]`))
+		})
+
+		It("records the outcome and duration of sends", func() {
+			config := Config{Notifications: Notifications{CFOrg: "org", CFSpace: "space"}, DryRun: true}
+			client := New(config, log.New(GinkgoWriter, "", 0)).WithDryRunOutput(ioutil.Discard).WithMetrics(metrics)
+
+			Expect(client.SendAlert(Alert{Product: "redis", Subject: "down"})).To(Succeed())
+
+			Expect(exposition()).To(ContainSubstring(`service_alerts_sends_total{backend="cf_notifications",outcome="dry_run"} 1`))
+			Expect(exposition()).To(ContainSubstring(`service_alerts_send_duration_seconds_count{backend="cf_notifications",outcome="dry_run"} 1`))
+		})
+
+		It("tells apart sends without recipients, failures and timeouts", func() {
+			client := New(Config{}, log.New(GinkgoWriter, "", 0))
+
+			Expect(client.sendOutcome(DeliveryReceipt{Recipients: []RecipientReceipt{{}}}, nil)).To(Equal(OutcomeSent))
+			Expect(client.sendOutcome(DeliveryReceipt{Recipients: []RecipientReceipt{}}, nil)).To(Equal(OutcomeNoRecipients))
+			Expect(client.sendOutcome(DeliveryReceipt{}, NoRecipientsError{})).To(Equal(OutcomeNoRecipients))
+			Expect(client.sendOutcome(DeliveryReceipt{}, HTTPRequestError{error: TimeoutError{}})).To(Equal(OutcomeTimedOut))
+			Expect(client.sendOutcome(DeliveryReceipt{}, RejectedError{})).To(Equal(OutcomeFailed))
+		})
+
+		It("records the number of alerts waiting for a digest", func() {
+			aggregator, err := NewDigestAggregator(Digest{Severity: "warning"}, &fakeDigestSender{}, clock.NewClock(), log.New(GinkgoWriter, "", 0))
+			Expect(err).NotTo(HaveOccurred())
+			aggregator.WithMetrics(metrics)
+
+			destination := Destination{CFOrg: "org", CFSpace: "space"}
+			aggregator.Collect(destination, Alert{Severity: SeverityInfo})
+			aggregator.Collect(destination, Alert{Severity: SeverityInfo})
+			Expect(exposition()).To(ContainSubstring(`service_alerts_queue_depth{queue="digest"} 2`))
+
+			Expect(aggregator.Flush()).To(Succeed())
+			Expect(exposition()).To(ContainSubstring(`service_alerts_queue_depth{queue="digest"} 0`))
+		})
+	})
+})
@@ -0,0 +1,90 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	ZeroRecipientsWarn  = "warn"
+	ZeroRecipientsError = "error"
+)
+
+// DeliveryReceipt is what the notifications service reported after accepting
+// a notification for a space: one entry per space developer it is emailed to.
+// Recipients is nil for dry runs and when the response could not be read.
+type DeliveryReceipt struct {
+	Destination Destination        `json:"destination"`
+	Recipients  []RecipientReceipt `json:"recipients"`
+}
+
+// RecipientReceipt is the notification sent to one recipient. Status is as
+// reported by the notifications service, usually "queued".
+type RecipientReceipt struct {
+	Recipient      string `json:"recipient"`
+	Email          string `json:"email,omitempty"`
+	NotificationID string `json:"notification_id"`
+	Status         string `json:"status"`
+}
+
+// NoRecipientsError is returned when notifications.zero_recipients is error
+// and the notifications service accepted a notification for a space without
+// anyone to send it to, usually because the space has no space developers
+type NoRecipientsError struct {
+	Destination Destination
+}
+
+func (e NoRecipientsError) Error() string {
+	return fmt.Sprintf("notification to %s has no recipients", e.Destination)
+}
+
+// readReceipt reads the recipients from a notifications service response. The
+// notification has been accepted by then, so a response that cannot be read
+// is logged rather than failing the alert. An empty response says nothing
+// about the recipients, so it has none rather than zero.
+func (c *ServiceAlertsClient) readReceipt(destination Destination, response *http.Response) DeliveryReceipt {
+	defer response.Body.Close()
+
+	recipients := []RecipientReceipt{}
+	if err := json.NewDecoder(response.Body).Decode(&recipients); err == io.EOF {
+		recipients = nil
+	} else if err != nil {
+		c.logger.Printf("Notification to %s was sent, but the notifications response is not parseable: %s", destination, err)
+		recipients = nil
+	}
+	return DeliveryReceipt{Destination: destination, Recipients: recipients}
+}
+
+// checkRecipients applies notifications.zero_recipients to receipts that say
+// there were no recipients
+func (c *ServiceAlertsClient) checkRecipients(receipt DeliveryReceipt) error {
+	if receipt.Recipients == nil || len(receipt.Recipients) > 0 {
+		return nil
+	}
+	if err := validateZeroRecipients(c.config.Notifications); err != nil {
+		return err
+	}
+
+	if c.config.Notifications.ZeroRecipients == ZeroRecipientsError {
+		return NoRecipientsError{Destination: receipt.Destination}
+	}
+	c.logger.Printf("Warning: notification to %s has no recipients, check that the space has space developers", receipt.Destination)
+	return nil
+}
+
+func validateZeroRecipients(config Notifications) error {
+	switch config.ZeroRecipients {
+	case "", ZeroRecipientsWarn, ZeroRecipientsError:
+		return nil
+	default:
+		return ConfigError{Section: "notifications", Err: fmt.Errorf("zero_recipients must be '%s' or '%s'", ZeroRecipientsWarn, ZeroRecipientsError)}
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("delivery receipts", func() {
+	var (
+		logs        *bytes.Buffer
+		config      Config
+		destination = Destination{CFOrg: "org", CFSpace: "space"}
+	)
+
+	BeforeEach(func() {
+		logs = &bytes.Buffer{}
+		config = Config{}
+	})
+
+	readReceipt := func(body string) DeliveryReceipt {
+		response := &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body))}
+		return New(config, log.New(logs, "", 0)).readReceipt(destination, response)
+	}
+
+	checkRecipients := func(receipt DeliveryReceipt) error {
+		return New(config, log.New(logs, "", 0)).checkRecipients(receipt)
+	}
+
+	It("reads the recipients from the response", func() {
+		receipt := readReceipt(`[{"email":"dev@example.com","notification_id":"n-1","recipient":"user-1","status":"queued"}]`)
+		Expect(receipt).To(Equal(DeliveryReceipt{
+			Destination: destination,
+			Recipients:  []RecipientReceipt{{Recipient: "user-1", Email: "dev@example.com", NotificationID: "n-1", Status: "queued"}},
+		}))
+	})
+
+	It("leaves the recipients unknown when the response is empty", func() {
+		config.Notifications.ZeroRecipients = ZeroRecipientsError
+		receipt := readReceipt("")
+		Expect(receipt.Recipients).To(BeNil())
+		Expect(logs.String()).To(BeEmpty())
+		Expect(checkRecipients(receipt)).To(Succeed())
+		Expect(New(config, log.New(logs, "", 0)).sendOutcome(receipt, nil)).To(Equal(OutcomeSent))
+	})
+
+	It("logs responses that are not parseable and leaves the recipients unknown", func() {
+		receipt := readReceipt("<html>")
+		Expect(receipt.Recipients).To(BeNil())
+		Expect(logs.String()).To(ContainSubstring("notifications response is not parseable"))
+		Expect(checkRecipients(receipt)).To(Succeed())
+	})
+
+	It("warns about no recipients by default", func() {
+		Expect(checkRecipients(DeliveryReceipt{Destination: destination, Recipients: []RecipientReceipt{}})).To(Succeed())
+		Expect(logs.String()).To(ContainSubstring("Warning: notification to org: org, space: space has no recipients"))
+	})
+
+	It("fails without recipients when zero_recipients is error", func() {
+		config.Notifications.ZeroRecipients = ZeroRecipientsError
+		err := checkRecipients(DeliveryReceipt{Destination: destination, Recipients: []RecipientReceipt{}})
+		Expect(err).To(Equal(NoRecipientsError{Destination: destination}))
+	})
+
+	It("rejects other zero_recipients settings", func() {
+		config.Notifications.ZeroRecipients = "ignore"
+		err := checkRecipients(DeliveryReceipt{Destination: destination, Recipients: []RecipientReceipt{}})
+		Expect(err).To(MatchError("invalid notifications config: zero_recipients must be 'warn' or 'error'"))
+	})
+})
@@ -0,0 +1,136 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Recipient is a user the notifications service emails for a space. Email is
+// empty when UAA could not be asked for it, for example because the
+// notifications client lacks the scim.read scope.
+type Recipient struct {
+	UserGUID string `json:"user_guid"`
+	Username string `json:"username"`
+	Email    string `json:"email,omitempty"`
+	Verified bool   `json:"verified"`
+}
+
+// Recipients lists the space developers of the destination, who are the users
+// the notifications service sends space notifications to
+func (c *ServiceAlertsClient) Recipients(destination Destination) ([]Recipient, error) {
+	if err := c.setupUaaUrl(); err != nil {
+		return nil, err
+	}
+
+	spaceGUID, err := c.obtainSpaceGUID(destination)
+	if err != nil {
+		return nil, err
+	}
+
+	cfUserToken, err := c.obtainCFUserToken()
+	if err != nil {
+		return nil, err
+	}
+
+	developers, err := c.spaceDevelopers(cfUserToken, spaceGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	notificationsToken, err := c.obtainNotificationsClientToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var recipients []Recipient
+	for _, developer := range developers {
+		recipient := Recipient{UserGUID: developer.Metadata.GUID, Username: developer.Entity.Username}
+		user, err := c.uaaUser(notificationsToken, developer.Metadata.GUID)
+		if err != nil {
+			c.logger.Printf("Failed to look up email of user %s in UAA: %s", developer.Metadata.GUID, err)
+		} else {
+			recipient.Email = primaryEmail(user.Emails)
+			recipient.Verified = user.Verified
+			if recipient.Username == "" {
+				recipient.Username = user.UserName
+			}
+		}
+		recipients = append(recipients, recipient)
+	}
+	return recipients, nil
+}
+
+func (c *ServiceAlertsClient) spaceDevelopers(cfUserToken, spaceGUID string) ([]CFUserResource, error) {
+	pageURL, err := joinURL(c.config.CloudController.URL, fmt.Sprintf("/v2/spaces/%s/developers", spaceGUID), "")
+	if err != nil {
+		return nil, err
+	}
+
+	var developers []CFUserResource
+	for pageURL != "" {
+		response, err := c.getCFApiURL(cfUserToken, pageURL)
+		if err != nil {
+			return nil, err
+		}
+
+		var page CFUsersResponse
+		err = json.NewDecoder(response.Body).Decode(&page)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("CF response not parseable: %s", err.Error())
+		}
+		developers = append(developers, page.Resources...)
+
+		pageURL = ""
+		if page.NextURL != "" {
+			if pageURL, err = resolveURL(c.config.CloudController.URL, page.NextURL); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return developers, nil
+}
+
+func (c *ServiceAlertsClient) uaaUser(token, userGUID string) (UAAUserResponse, error) {
+	userURL, err := joinURL(c.uaaUrl, "/Users/"+userGUID, "")
+	if err != nil {
+		return UAAUserResponse{}, err
+	}
+
+	req, err := http.NewRequest("GET", userURL, nil)
+	if err != nil {
+		return UAAUserResponse{}, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+
+	response, err := c.httpClient.doRequestWithRetries("UAA", req)
+	if err != nil {
+		return UAAUserResponse{}, err
+	}
+	defer response.Body.Close()
+
+	var user UAAUserResponse
+	if err := json.NewDecoder(response.Body).Decode(&user); err != nil {
+		return UAAUserResponse{}, fmt.Errorf("UAA response not parseable: %s", err.Error())
+	}
+	return user, nil
+}
+
+func primaryEmail(emails []UAAEmail) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) > 0 {
+		return emails[0].Value
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	redacted = "[REDACTED]"
+
+	// minRedactedSecretLength keeps very short configured secrets from
+	// replacing common words or digits in logs
+	minRedactedSecretLength = 4
+)
+
+var secretPatterns = []*regexp.Regexp{
+	// JSON fields, such as the access_token in UAA responses
+	regexp.MustCompile(`("(?i:access_token|refresh_token|id_token|client_secret|password|secret|token)"\s*:\s*")[^"]*(")`),
+	// form and query parameters
+	regexp.MustCompile(`((?:^|[?&\s])(?i:access_token|refresh_token|id_token|client_secret|password|token)=)[^&\s"]*`),
+	// Authorization header values and bearer tokens
+	regexp.MustCompile(`((?i:authorization)"?\s*[:=]\s*"?)[^"\r\n,}]+`),
+	regexp.MustCompile(`((?i:bearer)\s+)[A-Za-z0-9\-._~+/]+=*`),
+	// passwords in URLs
+	regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+(@)`),
+}
+
+// redactor removes credentials from text before it is logged or put into an
+// error: the secrets in the config wherever they appear, and anything that
+// looks like a token, password or Authorization header
+type redactor struct {
+	secrets []string
+}
+
+func newRedactor(config Config) redactor {
+	candidates := []string{
+		config.CloudController.Password,
+		config.Notifications.ClientSecret,
+		config.Acknowledgement.Secret,
+	}
+	candidates = append(candidates, config.Serve.BearerTokens...)
+
+	var secrets []string
+	for _, secret := range candidates {
+		if len(secret) >= minRedactedSecretLength {
+			secrets = append(secrets, secret)
+		}
+	}
+	return redactor{secrets: secrets}
+}
+
+func (r redactor) redact(text string) string {
+	for _, secret := range r.secrets {
+		text = strings.Replace(text, secret, redacted, -1)
+	}
+	for _, pattern := range secretPatterns {
+		text = pattern.ReplaceAllString(text, "${1}"+redacted+"${2}")
+	}
+	return text
+}
+
+// redactHeaders copies headers for logging, without the values of
+// Authorization and cookie headers
+func (r redactor) redactHeaders(headers http.Header) map[string]string {
+	logged := map[string]string{}
+	for name, values := range headers {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie":
+			logged[name] = redacted
+		default:
+			logged[name] = r.redact(strings.Join(values, ", "))
+		}
+	}
+	return logged
+}
@@ -7,10 +7,12 @@
 package client
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/cenk/backoff"
@@ -23,6 +25,14 @@ type RetryHTTPClient struct {
 	config     Config
 	httpClient *herottp.Client
 	logger     *log.Logger
+	redactor   redactor
+	metrics    Metrics
+
+	structuredLogger StructuredLogger
+
+	mutex         sync.Mutex
+	lastFailure   error
+	lastFailureAt time.Time
 }
 
 func NewRetryHTTPClient(config Config, logger *log.Logger) *RetryHTTPClient {
@@ -36,68 +46,118 @@ func NewRetryHTTPClient(config Config, logger *log.Logger) *RetryHTTPClient {
 		DisableTLSCertificateVerification: skipSSLValidation,
 	})
 
-	return &RetryHTTPClient{config: config, httpClient: httpClient, logger: logger}
+	return &RetryHTTPClient{config: config, httpClient: httpClient, logger: logger, redactor: newRedactor(config), metrics: noopMetrics{}}
 }
 
 func (r *RetryHTTPClient) doRequestWithRetries(label string, req *http.Request) (*http.Response, error) {
 	var apiResponse *http.Response
+	var attempt int
 
 	retryRequest := func() error {
 		var networkErr error
 
+		attempt++
 		apiResponse, networkErr = r.httpClient.Do(req)
+		r.logRequest(label, req, attempt, apiResponse)
+		if timeoutErr, ok := networkErr.(interface{ Timeout() bool }); ok && timeoutErr.Timeout() {
+			return r.recordFailure(HTTPRequestError{error: TimeoutError{Label: label, Timeout: httpClientTimeout, Err: networkErr}, config: r.config})
+		}
 		if networkErr != nil {
-			return HTTPRequestError{error: networkErr, config: r.config}
+			return r.recordFailure(HTTPRequestError{error: UnreachableError{Label: label, Err: networkErr}, config: r.config})
 		}
 
 		if retryableResponse(apiResponse) {
-			return HTTPRequestError{
-				error:  fmt.Errorf("%s expected to return HTTP 200, got %d. %s", label, apiResponse.StatusCode, responseBodyDetails(apiResponse)),
+			return r.recordFailure(HTTPRequestError{
+				error:  fmt.Errorf("%s expected to return HTTP 200, got %d. %s", label, apiResponse.StatusCode, r.responseBodyDetails(apiResponse)),
 				config: r.config,
-			}
+			})
 		}
 
 		return nil
 	}
 
-	retryError := backoff.RetryNotify(retryRequest, r.buildExponentialBackoff(), r.buildRetryLogging(label))
+	retryLogging := func(err error, next time.Duration) {
+		r.metrics.IncCounter(MetricRetries, Labels{"label": label})
+		if r.structuredLogger != nil {
+			data := r.requestData(label, req, attempt, apiResponse)
+			data["retry_in_seconds"] = int(next.Seconds())
+			r.structuredLogger.Error("request-failed", r.redactedError(err), data)
+			return
+		}
+		r.logger.Printf("Retrying in %d seconds, %s request error: %s", int(next.Seconds()), label, r.redactor.redact(err.Error()))
+	}
+
+	retryError := backoff.RetryNotify(retryRequest, r.buildExponentialBackoff(), retryLogging)
 	if retryError != nil {
-		r.logger.Printf("Giving up, %s request failed: %s", label, retryError)
+		if r.structuredLogger != nil {
+			r.structuredLogger.Error("request-gave-up", r.redactedError(retryError), r.requestData(label, req, attempt, apiResponse))
+		} else {
+			r.logger.Printf("Giving up, %s request failed: %s", label, r.redactor.redact(retryError.Error()))
+		}
 		return nil, retryError
 	}
 
 	if apiResponse.StatusCode != http.StatusOK {
-		failStatusCodeErr := fmt.Errorf("%s expected to return HTTP 200, got %d. %s", label, apiResponse.StatusCode, responseBodyDetails(apiResponse))
+		failStatusCodeErr := fmt.Errorf("%s expected to return HTTP 200, got %d. %s", label, apiResponse.StatusCode, r.responseBodyDetails(apiResponse))
+		switch {
+		case apiResponse.StatusCode == http.StatusUnauthorized || apiResponse.StatusCode == http.StatusForbidden:
+			return nil, AuthError{Label: label, StatusCode: apiResponse.StatusCode, Err: failStatusCodeErr}
+		case apiResponse.StatusCode >= http.StatusBadRequest && apiResponse.StatusCode < http.StatusInternalServerError:
+			return nil, RejectedError{Label: label, StatusCode: apiResponse.StatusCode, Err: failStatusCodeErr}
+		}
 		return nil, failStatusCodeErr
 	}
 
 	return apiResponse, nil
 }
 
-func (r *RetryHTTPClient) buildRetryLogging(label string) func(err error, next time.Duration) {
-	return func(err error, next time.Duration) {
-		r.logger.Printf("Retrying in %d seconds, %s request error: %s", int(next.Seconds()), label, err)
+func (r *RetryHTTPClient) recordFailure(err error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastFailure, r.lastFailureAt = err, time.Now()
+	return err
+}
+
+// failureSince is the last failed attempt made since the given time, so that
+// a timeout can say what was going wrong. Requests for other alerts sent at
+// the same time may have failed since, in which case it is theirs.
+func (r *RetryHTTPClient) failureSince(since time.Time) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.lastFailureAt.Before(since) {
+		return nil
+	}
+	return r.lastFailure
+}
+
+func (r *RetryHTTPClient) logRequest(label string, req *http.Request, attempt int, response *http.Response) {
+	if r.structuredLogger == nil {
+		return
 	}
+	data := r.requestData(label, req, attempt, response)
+	data["headers"] = r.redactor.redactHeaders(req.Header)
+	r.structuredLogger.Debug("request", data)
+}
+
+func (r *RetryHTTPClient) redactedError(err error) error {
+	return errors.New(r.redactor.redact(err.Error()))
 }
 
 func (r *RetryHTTPClient) buildExponentialBackoff() *backoff.ExponentialBackOff {
 	exponentialBackoff := backoff.NewExponentialBackOff()
 
-	maxElapsedTime := defaultGlobalTimeout
-	if r.config.GlobalTimeoutSeconds != 0 {
-		maxElapsedTime = time.Duration(r.config.GlobalTimeoutSeconds) * time.Second
-	}
-
 	exponentialBackoff.InitialInterval = 1 * time.Second
 	exponentialBackoff.RandomizationFactor = 0
 	exponentialBackoff.Multiplier = 2
 	exponentialBackoff.MaxInterval = 16 * time.Second
-	exponentialBackoff.MaxElapsedTime = maxElapsedTime
+	exponentialBackoff.MaxElapsedTime = r.config.SendTimeout()
 
 	return exponentialBackoff
 }
 
-func responseBodyDetails(response *http.Response) string {
+// responseBodyDetails is the response body for an error message, without any
+// credentials it contains
+func (r *RetryHTTPClient) responseBodyDetails(response *http.Response) string {
 	defer response.Body.Close()
 	body, err := ioutil.ReadAll(response.Body)
 
@@ -105,7 +165,7 @@ func responseBodyDetails(response *http.Response) string {
 	if err != nil {
 		details = fmt.Sprintf("Read body error: %s", err.Error())
 	} else {
-		details = fmt.Sprintf("Body: %s", string(body))
+		details = fmt.Sprintf("Body: %s", r.redactor.redact(string(body)))
 	}
 
 	return details
@@ -0,0 +1,126 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import "fmt"
+
+const DefaultReceiverName = "default"
+
+type Router struct {
+	root      Route
+	receivers map[string]Receiver
+}
+
+func NewRouter(config Config) (*Router, error) {
+	receivers, err := configuredReceivers(config)
+	if err != nil {
+		return nil, ConfigError{Section: "routing", Err: err}
+	}
+
+	root := config.Route
+	if len(root.Receivers) == 0 {
+		root.Receivers = []string{DefaultReceiverName}
+	}
+
+	router := &Router{root: root, receivers: receivers}
+	if err := router.validate(root); err != nil {
+		return nil, ConfigError{Section: "routing", Err: err}
+	}
+	return router, nil
+}
+
+func configuredReceivers(config Config) (map[string]Receiver, error) {
+	receivers := map[string]Receiver{
+		DefaultReceiverName: {
+			Name:        DefaultReceiverName,
+			Destination: Destination{CFOrg: config.Notifications.CFOrg, CFSpace: config.Notifications.CFSpace},
+		},
+	}
+	for _, receiver := range config.Receivers {
+		if receiver.Name == "" {
+			return nil, fmt.Errorf("receiver without a name")
+		}
+		receivers[receiver.Name] = receiver
+	}
+	return receivers, nil
+}
+
+// Route walks the routing tree depth first and returns the receivers of the
+// deepest matching routes. Sibling routes are only considered after a match
+// when the matching route has continue set.
+func (r *Router) Route(alert Alert) []Receiver {
+	names := r.match(r.root, alert)
+
+	var receivers []Receiver
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			receivers = append(receivers, r.receivers[name])
+		}
+	}
+	return receivers
+}
+
+func (r *Router) match(route Route, alert Alert) []string {
+	var names []string
+	for _, child := range route.Routes {
+		if !child.Match.matches(alert) {
+			continue
+		}
+
+		names = append(names, r.match(child, alert)...)
+		if !child.Continue {
+			break
+		}
+	}
+
+	if len(names) == 0 {
+		return route.Receivers
+	}
+	return names
+}
+
+func (r *Router) validate(route Route) error {
+	if route.Match.Severity != "" {
+		if _, err := ParseSeverity(route.Match.Severity); err != nil {
+			return err
+		}
+	}
+	for _, name := range route.Receivers {
+		if _, ok := r.receivers[name]; !ok {
+			return fmt.Errorf("unknown receiver: '%s'", name)
+		}
+	}
+	for _, child := range route.Routes {
+		if err := r.validate(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m RouteMatch) matches(alert Alert) bool {
+	if m.Product != "" && m.Product != alert.Product {
+		return false
+	}
+	if m.ServiceInstance != "" && m.ServiceInstance != alert.ServiceInstanceID {
+		return false
+	}
+	if m.Severity != "" {
+		minimum, _ := ParseSeverity(m.Severity)
+		if alert.Severity < minimum {
+			return false
+		}
+	}
+	for key, value := range m.Labels {
+		if alert.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Router", func() {
+	var config Config
+
+	receiverNames := func(receivers []Receiver) []string {
+		var names []string
+		for _, receiver := range receivers {
+			names = append(names, receiver.Name)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		config = Config{
+			Notifications: Notifications{CFOrg: "org", CFSpace: "space"},
+			Receivers: []Receiver{
+				{Name: "on-call", Destination: Destination{CFOrg: "org", CFSpace: "on-call"}},
+				{Name: "ops", Destination: Destination{CFOrg: "org", CFSpace: "ops"}},
+				{Name: "redis-team", Destination: Destination{CFOrg: "org", CFSpace: "redis"}},
+			},
+			Route: Route{
+				Receivers: []string{"ops"},
+				Routes: []Route{
+					{
+						Match:     RouteMatch{Product: "redis", Severity: "critical"},
+						Receivers: []string{"on-call"},
+						Continue:  true,
+					},
+					{
+						Match:     RouteMatch{Labels: map[string]string{"team": "redis"}},
+						Receivers: []string{"redis-team"},
+					},
+					{
+						Match:     RouteMatch{Product: "redis"},
+						Receivers: []string{"on-call"},
+					},
+				},
+			},
+		}
+	})
+
+	It("sends to the configured space when there is no routing tree", func() {
+		router, err := NewRouter(Config{Notifications: Notifications{CFOrg: "org", CFSpace: "space"}})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(router.Route(Alert{})).To(Equal([]Receiver{
+			{Name: DefaultReceiverName, Destination: Destination{CFOrg: "org", CFSpace: "space"}},
+		}))
+	})
+
+	It("falls back to the root receivers when no route matches", func() {
+		router, err := NewRouter(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(receiverNames(router.Route(Alert{Product: "mysql", Severity: SeverityCritical}))).To(Equal([]string{"ops"}))
+	})
+
+	It("continues to sibling routes after a match with continue set", func() {
+		router, err := NewRouter(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		alert := Alert{Product: "redis", Severity: SeverityCritical, Labels: map[string]string{"team": "redis"}}
+		Expect(receiverNames(router.Route(alert))).To(Equal([]string{"on-call", "redis-team"}))
+	})
+
+	It("stops at the first match without continue", func() {
+		router, err := NewRouter(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		alert := Alert{Product: "redis", Severity: SeverityWarning, Labels: map[string]string{"team": "redis"}}
+		Expect(receiverNames(router.Route(alert))).To(Equal([]string{"redis-team"}))
+	})
+
+	It("matches nested routes", func() {
+		config.Route.Routes = []Route{{
+			Match:     RouteMatch{Product: "redis"},
+			Receivers: []string{"redis-team"},
+			Routes: []Route{{
+				Match:     RouteMatch{ServiceInstance: "instance-1"},
+				Receivers: []string{"on-call"},
+			}},
+		}}
+		router, err := NewRouter(config)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(receiverNames(router.Route(Alert{Product: "redis", ServiceInstanceID: "instance-1"}))).To(Equal([]string{"on-call"}))
+		Expect(receiverNames(router.Route(Alert{Product: "redis", ServiceInstanceID: "instance-2"}))).To(Equal([]string{"redis-team"}))
+	})
+
+	It("rejects routes to unknown receivers", func() {
+		config.Route.Routes[0].Receivers = []string{"pager"}
+
+		_, err := NewRouter(config)
+		Expect(err).To(MatchError("invalid routing config: unknown receiver: 'pager'"))
+	})
+
+	It("rejects unknown severities", func() {
+		config.Route.Routes[0].Match.Severity = "urgent"
+
+		_, err := NewRouter(config)
+		Expect(err).To(MatchError("invalid routing config: unknown severity: 'urgent'"))
+	})
+
+	Context("when sending to one of several receivers fails", func() {
+		It("returns the receipts of the others and which receivers failed", func() {
+			server := fakeCloudFoundryServer("on-call")
+			defer server.Close()
+			config.CloudController.URL = server.URL
+			config.Notifications.ServiceURL = server.URL
+			config.GlobalTimeoutSeconds = 1
+			config.Route = Route{Receivers: []string{"ops", "on-call"}}
+
+			dispatcher, err := NewDispatcher(New(config, log.New(GinkgoWriter, "", 0)))
+			Expect(err).NotTo(HaveOccurred())
+
+			receipts, err := dispatcher.DispatchWithReceipts(Alert{Product: "redis", Subject: "down"})
+			Expect(err).To(BeAssignableToTypeOf(ReceiverErrors{}))
+			Expect(err.(ReceiverErrors).Receivers()).To(Equal([]string{"on-call"}))
+			Expect(err).To(MatchError(ContainSubstring("failed to send alert to receiver on-call: CF space not found: 'on-call'")))
+			Expect(receipts).To(HaveLen(1))
+			Expect(receipts[0].Destination).To(Equal(Destination{CFOrg: "org", CFSpace: "ops"}))
+		})
+	})
+})
+
+// fakeCloudFoundryServer answers the Cloud Controller, UAA and notifications
+// service requests of sending an alert, finding every space but missingSpace
+func fakeCloudFoundryServer(missingSpace string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/v2/info":
+			fmt.Fprintf(w, `{"token_endpoint": "http://%s"}`, req.Host)
+		case req.URL.Path == "/oauth/token":
+			fmt.Fprint(w, `{"access_token": "some-token", "expires_in": 600}`)
+		case strings.Contains(req.URL.Query().Get("q"), "name:"+missingSpace):
+			fmt.Fprint(w, `{"total_results": 0, "resources": []}`)
+		case strings.HasPrefix(req.URL.Path, "/v2/"):
+			fmt.Fprint(w, `{"total_results": 1, "resources": [{"metadata": {"guid": "some-guid"}}]}`)
+		default:
+			fmt.Fprint(w, `[{"recipient": "user-1"}]`)
+		}
+	}))
+}
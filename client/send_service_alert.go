@@ -18,31 +18,197 @@ import (
 	"path"
 	"strings"
 	"time"
+
+	"code.cloudfoundry.org/clock"
 )
 
-const defaultGlobalTimeout = 60 * time.Second
+const (
+	defaultGlobalTimeout = 60 * time.Second
+	tokenExpiryMargin    = 60 * time.Second
+)
 
 func (c *ServiceAlertsClient) SendServiceAlert(product, subject, serviceInstanceID, content string) error {
-	if err := c.setupUaaUrl(); err != nil {
+	return c.SendAlert(Alert{
+		Product:           product,
+		Subject:           subject,
+		ServiceInstanceID: serviceInstanceID,
+		Content:           content,
+		Severity:          SeverityCritical,
+	})
+}
+
+// SendAlert sends the alert to every receiver selected by the configured
+// routing tree. Without a routing tree this is the configured space.
+func (c *ServiceAlertsClient) SendAlert(alert Alert) error {
+	_, err := c.SendAlertWithReceipts(alert)
+	return err
+}
+
+// SendAlertWithReceipts is SendAlert, also returning the receipt of every
+// space the alert was sent to
+func (c *ServiceAlertsClient) SendAlertWithReceipts(alert Alert) ([]DeliveryReceipt, error) {
+	dispatcher, err := NewDispatcher(c)
+	if err != nil {
+		return nil, err
+	}
+	return dispatcher.DispatchWithReceipts(alert)
+}
+
+func (c *ServiceAlertsClient) SendAlertTo(destination Destination, alert Alert) error {
+	_, err := c.SendAlertToWithReceipt(destination, alert)
+	return err
+}
+
+func (c *ServiceAlertsClient) SendAlertToWithReceipt(destination Destination, alert Alert) (DeliveryReceipt, error) {
+	notificationRequest, err := c.createNotification(destination, alert)
+	if err != nil {
+		return DeliveryReceipt{}, err
+	}
+	return c.sendToDestination(destination, notificationRequest)
+}
+
+// SendDeferredAlerts sends alerts deferred by silences that have since ended
+func (c *ServiceAlertsClient) SendDeferredAlerts() error {
+	dispatcher, err := NewDispatcher(c)
+	if err != nil {
 		return err
 	}
+	return dispatcher.SendDeferred()
+}
+
+// silence drops or defers the alert when a silence matches it. receivers are
+// the only receivers a deferred alert is still to be sent to, or empty for all.
+func (c *ServiceAlertsClient) silence(alert Alert, receivers []string) (bool, error) {
+	if c.silences == nil {
+		return false, nil
+	}
+
+	now := time.Now()
+	silence, err := c.silences.Active(alert, now)
+	if err != nil || silence == nil {
+		return false, err
+	}
 
-	globalTimeout := defaultGlobalTimeout
-	if c.config.GlobalTimeoutSeconds != 0 {
-		globalTimeout = time.Duration(c.config.GlobalTimeoutSeconds) * time.Second
+	if silence.Action == SilenceActionDefer && c.config.DryRun {
+		c.logger.Printf("Dry run: would defer alert for %s, silenced by %s", alert.Product, silence.ID)
+		return true, nil
 	}
+	if silence.Action == SilenceActionDefer {
+		if alert.OccurredAt.IsZero() {
+			alert.OccurredAt = now
+		}
+		c.logger.Printf("Deferring alert for %s, silenced by %s", alert.Product, silence.ID)
+		c.metrics.IncCounter(MetricSends, Labels{"backend": BackendCFNotifications, "outcome": OutcomeDeferred})
+		return true, c.silences.keepDeferred(DeferredAlert{Alert: alert, SilenceID: silence.ID, DeferredAt: now, Receivers: receivers})
+	}
+
+	c.logger.Printf("Dropping alert for %s, silenced by %s", alert.Product, silence.ID)
+	c.metrics.IncCounter(MetricSends, Labels{"backend": BackendCFNotifications, "outcome": OutcomeDropped})
+	return true, nil
+}
 
-	errChan := make(chan error)
-	go c.sendServiceAlert(product, subject, serviceInstanceID, content, errChan)
+// SendDigestTo sends a single notification summarising all the given alerts
+func (c *ServiceAlertsClient) SendDigestTo(destination Destination, alerts []Alert) error {
+	_, err := c.SendDigestToWithReceipt(destination, alerts)
+	return err
+}
+
+func (c *ServiceAlertsClient) SendDigestToWithReceipt(destination Destination, alerts []Alert) (DeliveryReceipt, error) {
+	notificationRequest, err := c.createDigestNotification(destination, alerts)
+	if err != nil {
+		return DeliveryReceipt{}, err
+	}
+	return c.sendToDestination(destination, notificationRequest)
+}
+
+func (c *ServiceAlertsClient) DefaultDestination() Destination {
+	return Destination{CFOrg: c.config.Notifications.CFOrg, CFSpace: c.config.Notifications.CFSpace}
+}
+
+// localeFor is the locale configured for the space, either as
+// notifications.locale for the default space or on a receiver. Spaces
+// without one get English.
+func (c *ServiceAlertsClient) localeFor(destination Destination) string {
+	if destination == c.DefaultDestination() && c.config.Notifications.Locale != "" {
+		return c.config.Notifications.Locale
+	}
+	for _, receiver := range c.config.Receivers {
+		if receiver.Destination == destination && receiver.Locale != "" {
+			return receiver.Locale
+		}
+	}
+	return DefaultLocale
+}
+
+type sendResult struct {
+	receipt DeliveryReceipt
+	err     error
+}
+
+// sendToDestination sends the notification and records its outcome and how
+// long it took
+func (c *ServiceAlertsClient) sendToDestination(destination Destination, notificationRequest SpaceNotificationRequest) (DeliveryReceipt, error) {
+	started := time.Now()
+	receipt, err := c.deliver(destination, notificationRequest)
+
+	labels := Labels{"backend": BackendCFNotifications, "outcome": c.sendOutcome(receipt, err)}
+	c.metrics.IncCounter(MetricSends, labels)
+	c.metrics.ObserveHistogram(MetricSendDuration, labels, time.Since(started).Seconds())
+	return receipt, err
+}
+
+func (c *ServiceAlertsClient) sendOutcome(receipt DeliveryReceipt, err error) string {
+	var noRecipientsErr NoRecipientsError
+	var timeoutErr TimeoutError
+	switch {
+	case errors.As(err, &noRecipientsErr):
+		return OutcomeNoRecipients
+	case errors.As(err, &timeoutErr):
+		return OutcomeTimedOut
+	case err != nil:
+		return OutcomeFailed
+	case c.config.DryRun:
+		return OutcomeDryRun
+	case receipt.Recipients != nil && len(receipt.Recipients) == 0:
+		return OutcomeNoRecipients
+	}
+	return OutcomeSent
+}
+
+func (c *ServiceAlertsClient) deliver(destination Destination, notificationRequest SpaceNotificationRequest) (DeliveryReceipt, error) {
+	if c.config.DryRun {
+		return DeliveryReceipt{Destination: destination}, c.printNotification(destination, notificationRequest)
+	}
+
+	if err := c.setupUaaUrl(); err != nil {
+		return DeliveryReceipt{}, err
+	}
+
+	globalTimeout := c.config.SendTimeout()
+
+	started := time.Now()
+	resultChan := make(chan sendResult, 1)
+	go c.sendServiceAlert(destination, notificationRequest, resultChan)
 	select {
-	case err := <-errChan:
-		return err
+	case result := <-resultChan:
+		if httpErr, ok := result.err.(HTTPRequestError); ok {
+			httpErr.destination = &destination
+			return DeliveryReceipt{}, httpErr
+		}
+		if result.err != nil {
+			return DeliveryReceipt{}, result.err
+		}
+		return result.receipt, c.checkRecipients(result.receipt)
 	case <-time.After(globalTimeout):
-		return HTTPRequestError{error: errors.New("sending service alert timed out"), config: c.config}
+		timeoutErr := TimeoutError{Timeout: globalTimeout, Err: c.httpClient.failureSince(started)}
+		return DeliveryReceipt{}, HTTPRequestError{error: timeoutErr, config: c.config, destination: &destination}
 	}
 }
 
 func (c *ServiceAlertsClient) setupUaaUrl() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
 	if c.uaaUrl == "" {
 		uaaUrl, err := c.getUaaUrl()
 		if err != nil {
@@ -79,63 +245,115 @@ func (c *ServiceAlertsClient) getUaaUrl() (string, error) {
 	return infoResponseBody.UAAUrl, nil
 }
 
-func (c *ServiceAlertsClient) sendServiceAlert(product, subject, serviceInstanceID, content string, errChan chan<- error) {
-	spaceGUID, err := c.obtainSpaceGUID()
+func (c *ServiceAlertsClient) sendServiceAlert(destination Destination, notificationRequest SpaceNotificationRequest, resultChan chan<- sendResult) {
+	receipt, err := c.sendServiceAlertWithTokens(destination, notificationRequest)
 	if err != nil {
-		errChan <- err
-		return
+		// A cached token may have been revoked, so fetch fresh ones next time
+		c.forgetTokens()
 	}
+	resultChan <- sendResult{receipt: receipt, err: err}
+}
 
-	token, err := c.obtainNotificationsClientToken()
+func (c *ServiceAlertsClient) sendServiceAlertWithTokens(destination Destination, notificationRequest SpaceNotificationRequest) (DeliveryReceipt, error) {
+	spaceGUID, err := c.obtainSpaceGUID(destination)
 	if err != nil {
-		errChan <- err
-		return
+		return DeliveryReceipt{}, err
 	}
-	notificationRequest, err := c.createNotification(product, subject, serviceInstanceID, content)
+
+	token, err := c.obtainNotificationsClientToken()
 	if err != nil {
-		errChan <- err
-		return
+		return DeliveryReceipt{}, err
 	}
 
-	errChan <- c.sendNotification(token, notificationRequest, spaceGUID)
+	return c.sendNotification(token, notificationRequest, destination, spaceGUID)
 }
 
-func (c *ServiceAlertsClient) sendNotification(uaaToken string, notificationRequest SpaceNotificationRequest, spaceGUID string) error {
+func (c *ServiceAlertsClient) sendNotification(uaaToken string, notificationRequest SpaceNotificationRequest, destination Destination, spaceGUID string) (DeliveryReceipt, error) {
 	reqBytes, err := json.Marshal(notificationRequest)
 	if err != nil {
-		return err
+		return DeliveryReceipt{}, err
 	}
 
 	sendNotificationRequestURL, err := joinURL(c.config.Notifications.ServiceURL, fmt.Sprintf("/spaces/%s", spaceGUID), "")
 	req, err := http.NewRequest("POST", sendNotificationRequestURL, bytes.NewReader(reqBytes))
 	if err != nil {
-		return err
+		return DeliveryReceipt{}, err
 	}
 
 	req.Header.Set("X-NOTIFICATIONS-VERSION", "1")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", uaaToken))
 	req.Header.Set("Content-Type", "application/json")
 
-	_, responseErr := c.httpClient.doRequestWithRetries("CF Notifications", req)
+	response, responseErr := c.httpClient.doRequestWithRetries("CF Notifications", req)
 	if responseErr != nil {
-		return responseErr
+		return DeliveryReceipt{}, responseErr
 	}
 
-	return nil
+	return c.readReceipt(destination, response), nil
 }
 
-func (c *ServiceAlertsClient) createNotification(product, subject, serviceInstanceID, content string) (SpaceNotificationRequest, error) {
-	textBody, err := templateEmailBody(product, serviceInstanceID, content, time.Now())
+func (c *ServiceAlertsClient) createNotification(destination Destination, alert Alert) (SpaceNotificationRequest, error) {
+	if c.templatesErr != nil {
+		return SpaceNotificationRequest{}, c.templatesErr
+	}
+
+	var ackURL string
+	if ack := c.config.Acknowledgement; ack.URL != "" && ack.Secret != "" {
+		var err error
+		ackURL, err = NewAckSigner(ack, clock.NewClock()).URL(ack.URL, alert.Fingerprint())
+		if err != nil {
+			return SpaceNotificationRequest{}, err
+		}
+	}
+
+	locale, now := c.localeFor(destination), time.Now()
+	var reference string
+	var saved bool
+	return c.fitBudget(len(alert.Content), func(maxContentBytes int) (SpaceNotificationRequest, error) {
+		fitted := alert
+		if maxContentBytes < len(alert.Content) {
+			if !saved {
+				reference, saved = c.fullContentReference(alert), true
+			}
+			fitted.Content = elide(alert.Content, maxContentBytes) + reference
+		}
+
+		email, err := c.templates.Render(fitted, locale, ackURL, now)
+		if err != nil {
+			return SpaceNotificationRequest{}, err
+		}
+
+		return SpaceNotificationRequest{
+			KindID:  DummyKindID,
+			Subject: email.Subject,
+			Text:    email.Text,
+			HTML:    email.HTML,
+			ReplyTo: c.config.Notifications.ReplyTo,
+		}, nil
+	})
+}
+
+func (c *ServiceAlertsClient) createDigestNotification(destination Destination, alerts []Alert) (SpaceNotificationRequest, error) {
+	if c.templatesErr != nil {
+		return SpaceNotificationRequest{}, c.templatesErr
+	}
+
+	locale := c.localeFor(destination)
+	textBody, err := templateDigestBody(alerts, locale, time.Now(), c.templates.timestamps)
 	if err != nil {
 		return SpaceNotificationRequest{}, err
 	}
-
-	return SpaceNotificationRequest{
-		KindID:  DummyKindID,
-		Subject: fmt.Sprintf("[Service Alert][%s] %s", product, subject),
-		Text:    textBody,
-		ReplyTo: c.config.Notifications.ReplyTo,
-	}, nil
+	catalog, _ := LookupLocale(locale)
+	subject := "[Service Alert Digest] " + fmt.Sprintf(catalog.Messages.DigestSubject, len(alerts))
+
+	return c.fitBudget(len(textBody), func(maxContentBytes int) (SpaceNotificationRequest, error) {
+		return SpaceNotificationRequest{
+			KindID:  DummyKindID,
+			Subject: subject,
+			Text:    elide(textBody, maxContentBytes),
+			ReplyTo: c.config.Notifications.ReplyTo,
+		}, nil
+	})
 }
 
 func (c *ServiceAlertsClient) obtainNotificationsClientToken() (string, error) {
@@ -146,24 +364,66 @@ func (c *ServiceAlertsClient) obtainCFUserToken() (string, error) {
 	return c.obtainUAAToken(c.config.CloudController.User, c.config.CloudController.Password, "password")
 }
 
+// WarmUp looks up UAA and obtains both tokens ahead of the first alert, so
+// that long running processes can report configuration problems early
+func (c *ServiceAlertsClient) WarmUp() error {
+	if err := c.setupUaaUrl(); err != nil {
+		return err
+	}
+	if _, err := c.obtainCFUserToken(); err != nil {
+		return err
+	}
+	_, err := c.obtainNotificationsClientToken()
+	return err
+}
+
 func (c *ServiceAlertsClient) obtainUAAToken(username, password, grantType string) (string, error) {
+	cacheKey := grantType + ":" + username
+	c.mutex.Lock()
+	cached, ok := c.tokens[cacheKey]
+	c.mutex.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	response, err := c.requestUAAToken(username, password, grantType)
+	if err != nil {
+		return errs(err)
+	}
+
+	expiresIn := time.Duration(response.ExpiresIn) * time.Second
+	if expiresIn > tokenExpiryMargin {
+		c.mutex.Lock()
+		c.tokens[cacheKey] = cachedToken{token: response.Token, expiresAt: time.Now().Add(expiresIn - tokenExpiryMargin)}
+		c.mutex.Unlock()
+	}
+	return response.Token, nil
+}
+
+func (c *ServiceAlertsClient) forgetTokens() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.tokens = map[string]cachedToken{}
+}
+
+func (c *ServiceAlertsClient) requestUAAToken(username, password, grantType string) (UAATokenResponse, error) {
 	uaaTokenReq, constructRequestErr := c.constructRequestForGrantType(username, password, grantType)
 	if constructRequestErr != nil {
-		return errs(constructRequestErr)
+		return UAATokenResponse{}, constructRequestErr
 	}
 
 	uaaTokenResp, uaaTokenReqError := c.httpClient.doRequestWithRetries("UAA", uaaTokenReq)
 	if uaaTokenReqError != nil {
-		return errs(uaaTokenReqError)
+		return UAATokenResponse{}, uaaTokenReqError
 	}
 
 	defer uaaTokenResp.Body.Close()
 	var uaaTokenRespBody UAATokenResponse
 	if unmarshalBodyError := json.NewDecoder(uaaTokenResp.Body).Decode(&uaaTokenRespBody); unmarshalBodyError != nil {
-		return errs(fmt.Errorf("UAA response not parseable: %s", unmarshalBodyError.Error()))
+		return UAATokenResponse{}, fmt.Errorf("UAA response not parseable: %s", unmarshalBodyError.Error())
 	}
 
-	return uaaTokenRespBody.Token, nil
+	return uaaTokenRespBody, nil
 }
 
 func (c *ServiceAlertsClient) constructRequestForGrantType(username, password, grantType string) (*http.Request, error) {
@@ -201,6 +461,10 @@ func (c *ServiceAlertsClient) sendCFApiRequest(uaaToken string, apiRequest CFApi
 		return nil, urlErr
 	}
 
+	return c.getCFApiURL(uaaToken, apiRequestURL)
+}
+
+func (c *ServiceAlertsClient) getCFApiURL(uaaToken, apiRequestURL string) (*http.Response, error) {
 	req, buildRequestErr := http.NewRequest("GET", apiRequestURL, nil)
 	if buildRequestErr != nil {
 		return nil, buildRequestErr
@@ -216,39 +480,39 @@ func (c *ServiceAlertsClient) sendCFApiRequest(uaaToken string, apiRequest CFApi
 	return apiResponse, nil
 }
 
-func (c *ServiceAlertsClient) obtainSpaceGUID() (string, error) {
+func (c *ServiceAlertsClient) obtainSpaceGUID(destination Destination) (string, error) {
 	cfUserToken, err := c.obtainCFUserToken()
 	if err != nil {
 		return errs(err)
 	}
 
-	getOrganisationRequest := c.createOrgQueryRequest()
+	getOrganisationRequest := createOrgQueryRequest(destination.CFOrg)
 	orgGUID, err := c.obtainGUIDUsingRequest(cfUserToken, getOrganisationRequest)
 	if err != nil {
-		return errs(formattedCFError("org", c.config.Notifications.CFOrg, err))
+		return errs(formattedCFError("org", destination.CFOrg, err))
 	}
 
-	getSpaceRequest := c.createSpaceQueryRequest(orgGUID)
+	getSpaceRequest := createSpaceQueryRequest(orgGUID, destination.CFSpace)
 	spaceGUID, err := c.obtainGUIDUsingRequest(cfUserToken, getSpaceRequest)
 	if err != nil {
-		return errs(formattedCFError("space", c.config.Notifications.CFSpace, err))
+		return errs(formattedCFError("space", destination.CFSpace, err))
 	}
 
 	return spaceGUID, nil
 }
 
-func (c *ServiceAlertsClient) createOrgQueryRequest() CFApiRequest {
+func createOrgQueryRequest(orgName string) CFApiRequest {
 	orgQueryRequest := CFApiRequest{
 		Path:   "/v2/organizations",
-		Filter: fmt.Sprintf("name:%s", c.config.Notifications.CFOrg),
+		Filter: fmt.Sprintf("name:%s", orgName),
 	}
 	return orgQueryRequest
 }
 
-func (c *ServiceAlertsClient) createSpaceQueryRequest(orgGUID string) CFApiRequest {
+func createSpaceQueryRequest(orgGUID, spaceName string) CFApiRequest {
 	spaceQueryRequest := CFApiRequest{
 		Path:   fmt.Sprintf("/v2/organizations/%s/spaces", orgGUID),
-		Filter: fmt.Sprintf("name:%s", c.config.Notifications.CFSpace),
+		Filter: fmt.Sprintf("name:%s", spaceName),
 	}
 	return spaceQueryRequest
 }
@@ -274,7 +538,7 @@ func (c *ServiceAlertsClient) obtainGUIDUsingRequest(token string, request CFApi
 func formattedCFError(cfResourceType, cfResourceName string, err error) error {
 	switch err := err.(type) {
 	case CFResourceNotFound:
-		return fmt.Errorf("CF %s not found: '%s'", cfResourceType, cfResourceName)
+		return NotFoundError{Resource: cfResourceType, Name: cfResourceName, Err: err}
 	default:
 		return err
 	}
@@ -307,6 +571,20 @@ func joinURL(base, urlPath, filter string) (string, error) {
 	return u.String(), nil
 }
 
+// resolveURL resolves references such as the next_url of paginated CF API
+// responses, which are a path and query, against the base URL
+func resolveURL(base, reference string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return errs(err)
+	}
+	referenceURL, err := url.Parse(reference)
+	if err != nil {
+		return errs(err)
+	}
+	return baseURL.ResolveReference(referenceURL).String(), nil
+}
+
 func errs(err error) (string, error) {
 	return "", err
 }
@@ -0,0 +1,165 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Server is the HTTP API of the alert gateway. Callers authenticate with one
+// of the configured bearer tokens or, when a client CA is configured, with a
+// verified client certificate.
+type Server struct {
+	config     Serve
+	dispatcher *Dispatcher
+	logger     *log.Logger
+	metrics    Metrics
+	mux        *http.ServeMux
+	ready      int32
+	inFlight   int64
+}
+
+type alertResponse struct {
+	Status      string `json:"status,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+func NewServer(config Serve, dispatcher *Dispatcher, logger *log.Logger) (*Server, error) {
+	if len(config.BearerTokens) == 0 && config.TLS.ClientCAFile == "" {
+		return nil, ConfigError{Section: "serve", Err: errors.New("bearer_tokens or tls.client_ca_file must be set")}
+	}
+
+	server := &Server{config: config, dispatcher: dispatcher, logger: logger, metrics: noopMetrics{}, mux: http.NewServeMux()}
+	server.HandlePublic("/health", http.HandlerFunc(server.health))
+	server.HandlePublic("/ready", http.HandlerFunc(server.readiness))
+	server.Handle("/alerts", http.HandlerFunc(server.postAlert))
+	return server, nil
+}
+
+// WithMetrics records the number of alerts the server is sending
+func (s *Server) WithMetrics(metrics Metrics) *Server {
+	s.metrics = metrics
+	return s
+}
+
+// Handle registers a handler that requires callers to authenticate
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, s.authenticated(handler))
+}
+
+// HandlePublic registers a handler anyone who can reach the server may call
+func (s *Server) HandlePublic(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) SetReady(ready bool) {
+	var value int32
+	if ready {
+		value = 1
+	}
+	atomic.StoreInt32(&s.ready, value)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mux.ServeHTTP(w, req)
+}
+
+func (s *Server) health(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, http.StatusOK, alertResponse{Status: "ok"})
+}
+
+func (s *Server) readiness(w http.ResponseWriter, req *http.Request) {
+	if atomic.LoadInt32(&s.ready) == 0 {
+		writeJSON(w, http.StatusServiceUnavailable, alertResponse{Status: "not ready"})
+		return
+	}
+	writeJSON(w, http.StatusOK, alertResponse{Status: "ready"})
+}
+
+func (s *Server) postAlert(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		writeJSON(w, http.StatusMethodNotAllowed, alertResponse{Error: "method not allowed"})
+		return
+	}
+
+	alert := Alert{Severity: SeverityCritical}
+	if err := json.NewDecoder(req.Body).Decode(&alert); err != nil {
+		writeJSON(w, http.StatusBadRequest, alertResponse{Error: "alert not parseable: " + err.Error()})
+		return
+	}
+	if err := alert.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, alertResponse{Error: err.Error()})
+		return
+	}
+
+	s.dispatch(w, alert)
+}
+
+func (s *Server) dispatch(w http.ResponseWriter, alert Alert) {
+	s.recordInFlight(1)
+	defer s.recordInFlight(-1)
+
+	fingerprint := alert.Fingerprint()
+	if err := s.dispatcher.Dispatch(alert); err != nil {
+		s.logger.Printf("Failed to send alert %s: %s", fingerprint, err)
+		writeJSON(w, http.StatusBadGateway, alertResponse{Fingerprint: fingerprint, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, alertResponse{Status: "sent", Fingerprint: fingerprint})
+}
+
+func (s *Server) recordInFlight(delta int64) {
+	inFlight := atomic.AddInt64(&s.inFlight, delta)
+	s.metrics.SetGauge(MetricQueueDepth, Labels{"queue": "in_flight"}, float64(inFlight))
+}
+
+func (s *Server) authenticated(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if s.hasClientCertificate(req) || s.hasBearerToken(req) {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		if len(s.config.BearerTokens) > 0 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		writeJSON(w, http.StatusUnauthorized, alertResponse{Error: "unauthorized"})
+	})
+}
+
+func (s *Server) hasClientCertificate(req *http.Request) bool {
+	return s.config.TLS.ClientCAFile != "" && req.TLS != nil && len(req.TLS.VerifiedChains) > 0
+}
+
+func (s *Server) hasBearerToken(req *http.Request) bool {
+	header := req.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return false
+	}
+
+	token := []byte(strings.TrimPrefix(header, "Bearer "))
+	for _, expected := range s.config.BearerTokens {
+		if expected != "" && subtle.ConstantTimeCompare(token, []byte(expected)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var server *Server
+
+	request := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		logger := log.New(GinkgoWriter, "", 0)
+		dispatcher, err := NewDispatcher(New(Config{}, logger))
+		Expect(err).NotTo(HaveOccurred())
+
+		server, err = NewServer(Serve{BearerTokens: []string{"some-token"}}, dispatcher, logger)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("requires an authentication method to be configured", func() {
+		_, err := NewServer(Serve{}, nil, log.New(GinkgoWriter, "", 0))
+		Expect(err).To(MatchError("invalid serve config: bearer_tokens or tls.client_ca_file must be set"))
+	})
+
+	It("rejects alerts without a valid bearer token", func() {
+		Expect(request("POST", "/alerts", "", `{"product": "redis"}`).Code).To(Equal(http.StatusUnauthorized))
+		Expect(request("POST", "/alerts", "wrong-token", `{"product": "redis"}`).Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("rejects alerts that cannot be parsed", func() {
+		response := request("POST", "/alerts", "some-token", `{"product": `)
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("alert not parseable"))
+	})
+
+	It("rejects alerts without a product", func() {
+		response := request("POST", "/alerts", "some-token", `{"subject": "down"}`)
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("product is required"))
+	})
+
+	It("only accepts POST for alerts", func() {
+		Expect(request("GET", "/alerts", "some-token", "").Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+
+	It("reports health without authentication", func() {
+		Expect(request("GET", "/health", "", "").Code).To(Equal(http.StatusOK))
+	})
+
+	It("reports readiness once set ready", func() {
+		Expect(request("GET", "/ready", "", "").Code).To(Equal(http.StatusServiceUnavailable))
+		server.SetReady(true)
+		Expect(request("GET", "/ready", "", "").Code).To(Equal(http.StatusOK))
+		server.SetReady(false)
+		Expect(request("GET", "/ready", "", "").Code).To(Equal(http.StatusServiceUnavailable))
+	})
+})
@@ -8,27 +8,84 @@ package client
 
 import (
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"sync"
+	"time"
 )
 
 type ServiceAlertsClient struct {
-	config     Config
-	uaaUrl     string
-	httpClient *RetryHTTPClient
-	logger     *log.Logger
+	config       Config
+	uaaUrl       string
+	httpClient   *RetryHTTPClient
+	logger       *log.Logger
+	metrics      Metrics
+	dryRunOutput io.Writer
+	templates    *EmailTemplates
+	templatesErr error
+	silences     *SilenceStore
+
+	mutex  sync.Mutex
+	tokens map[string]cachedToken
+}
+
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
 }
 
 func New(config Config, logger *log.Logger) *ServiceAlertsClient {
 	httpClient := NewRetryHTTPClient(config, logger)
+	templates, templatesErr := NewEmailTemplates(config.Templates)
+
+	var silences *SilenceStore
+	if config.SilencesFile != "" {
+		silences = NewSilenceStore(config.SilencesFile)
+	}
+
+	return &ServiceAlertsClient{
+		config:       config,
+		httpClient:   httpClient,
+		logger:       logger,
+		metrics:      noopMetrics{},
+		dryRunOutput: os.Stdout,
+		templates:    templates,
+		templatesErr: templatesErr,
+		silences:     silences,
+		tokens:       map[string]cachedToken{},
+	}
+}
+
+// WithDryRunOutput sets where notifications are written instead of being sent
+// when dry_run is set in the config. The default is stdout.
+func (c *ServiceAlertsClient) WithDryRunOutput(output io.Writer) *ServiceAlertsClient {
+	c.dryRunOutput = output
+	return c
+}
 
-	return &ServiceAlertsClient{config: config, httpClient: httpClient, logger: logger}
+// Silences returns the store of the silences_file in the config, shared by
+// everything using this client, or nil when it is not set
+func (c *ServiceAlertsClient) Silences() *SilenceStore {
+	return c.silences
 }
 
+// HTTPRequestError is returned when an alert could not be sent to a space
+// because requests kept failing or timed out. It wraps the reason, such as an
+// UnreachableError or a TimeoutError.
 type HTTPRequestError struct {
 	error
-	config Config
+	config      Config
+	destination *Destination
+}
+
+func (n HTTPRequestError) Unwrap() error {
+	return n.error
 }
 
 func (n HTTPRequestError) ErrorMessageForUser() string {
+	if n.destination != nil {
+		return fmt.Sprintf("failed to send notification to %s", n.destination)
+	}
 	return fmt.Sprintf("failed to send notification to org: %s, space: %s", n.config.Notifications.CFOrg, n.config.Notifications.CFSpace)
 }
@@ -0,0 +1,315 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+const (
+	SilenceActionDrop  = "drop"
+	SilenceActionDefer = "defer"
+
+	RecurrenceDaily  = "daily"
+	RecurrenceWeekly = "weekly"
+)
+
+// Silence suppresses matching alerts during a time window. Recurring silences
+// repeat the window from StartsAt to EndsAt every day or week, at the same
+// wall clock time in Timezone.
+type Silence struct {
+	ID              string            `json:"id"`
+	Comment         string            `json:"comment,omitempty"`
+	Product         string            `json:"product,omitempty"`
+	ServiceInstance string            `json:"service_instance,omitempty"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	Action          string            `json:"action"`
+	StartsAt        time.Time         `json:"starts_at"`
+	EndsAt          time.Time         `json:"ends_at"`
+	Timezone        string            `json:"timezone,omitempty"`
+	Recurrence      string            `json:"recurrence,omitempty"`
+	ExpiredAt       *time.Time        `json:"expired_at,omitempty"`
+}
+
+func (s Silence) Validate() error {
+	if s.Action != SilenceActionDrop && s.Action != SilenceActionDefer {
+		return fmt.Errorf("invalid silence: action must be '%s' or '%s'", SilenceActionDrop, SilenceActionDefer)
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return fmt.Errorf("invalid silence: end must be after start")
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("invalid silence: %s", err)
+	}
+	switch s.Recurrence {
+	case "":
+	case RecurrenceDaily, RecurrenceWeekly:
+		if s.EndsAt.Sub(s.StartsAt) > s.period() {
+			return fmt.Errorf("invalid silence: a %s window must not be longer than %s", s.Recurrence, s.period())
+		}
+	default:
+		return fmt.Errorf("invalid silence: recurrence must be '%s' or '%s'", RecurrenceDaily, RecurrenceWeekly)
+	}
+	return nil
+}
+
+func (s Silence) Matches(alert Alert) bool {
+	if s.Product != "" && s.Product != alert.Product {
+		return false
+	}
+	if s.ServiceInstance != "" && s.ServiceInstance != alert.ServiceInstanceID {
+		return false
+	}
+	for key, value := range s.Labels {
+		if alert.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Silence) Expired(now time.Time) bool {
+	return s.ExpiredAt != nil && !now.Before(*s.ExpiredAt)
+}
+
+func (s Silence) ActiveAt(now time.Time) bool {
+	if s.Expired(now) || now.Before(s.StartsAt) {
+		return false
+	}
+	if s.Recurrence == "" {
+		return now.Before(s.EndsAt)
+	}
+
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return false
+	}
+
+	// Walk back over every day a window covering now could have started on,
+	// building each candidate start from the wall clock so that DST changes
+	// do not shift the window
+	start := s.StartsAt.In(location)
+	localNow := now.In(location)
+	window := s.EndsAt.Sub(s.StartsAt)
+	for daysAgo := 0; daysAgo <= int(window/(24*time.Hour))+1; daysAgo++ {
+		day := localNow.AddDate(0, 0, -daysAgo)
+		occurrence := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), 0, location)
+		if s.Recurrence == RecurrenceWeekly && occurrence.Weekday() != start.Weekday() {
+			continue
+		}
+		if !occurrence.Before(s.StartsAt) && !now.Before(occurrence) && now.Before(occurrence.Add(window)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Silence) period() time.Duration {
+	if s.Recurrence == RecurrenceWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// DeferredAlert is an alert held back by a silence. Receivers names the
+// receivers it is still to be sent to, when sending it to the others has
+// already succeeded; when empty it is sent to every receiver it is routed to.
+type DeferredAlert struct {
+	Alert      Alert     `json:"alert"`
+	SilenceID  string    `json:"silence_id"`
+	DeferredAt time.Time `json:"deferred_at"`
+	Receivers  []string  `json:"receivers,omitempty"`
+}
+
+type silencesFile struct {
+	Silences []Silence       `json:"silences"`
+	Deferred []DeferredAlert `json:"deferred"`
+}
+
+// SilenceStore keeps silences and the alerts they deferred in a local JSON file.
+// Changes are serialised with a lock file next to it, so that several
+// processes can share the file.
+type SilenceStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewSilenceStore(path string) *SilenceStore {
+	return &SilenceStore{path: path}
+}
+
+func (s *SilenceStore) List() ([]Silence, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	contents, err := s.read()
+	return contents.Silences, err
+}
+
+func (s *SilenceStore) Create(silence Silence) (Silence, error) {
+	if silence.ID == "" {
+		silence.ID = uuid.New()
+	}
+	if err := silence.Validate(); err != nil {
+		return Silence{}, err
+	}
+
+	return silence, s.update(func(contents *silencesFile) error {
+		contents.Silences = append(contents.Silences, silence)
+		return nil
+	})
+}
+
+func (s *SilenceStore) Expire(id string, now time.Time) error {
+	return s.update(func(contents *silencesFile) error {
+		for i := range contents.Silences {
+			if contents.Silences[i].ID == id {
+				contents.Silences[i].ExpiredAt = &now
+				return nil
+			}
+		}
+		return fmt.Errorf("silence not found: '%s'", id)
+	})
+}
+
+// Active returns the first silence matching the alert at the given time, if any
+func (s *SilenceStore) Active(alert Alert, now time.Time) (*Silence, error) {
+	silences, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	return findActiveSilence(silences, alert, now), nil
+}
+
+func (s *SilenceStore) Defer(alert Alert, silenceID string, now time.Time) error {
+	return s.keepDeferred(DeferredAlert{Alert: alert, SilenceID: silenceID, DeferredAt: now})
+}
+
+func (s *SilenceStore) keepDeferred(deferred DeferredAlert) error {
+	return s.update(func(contents *silencesFile) error {
+		contents.Deferred = append(contents.Deferred, deferred)
+		return nil
+	})
+}
+
+// TakeDeferred removes and returns deferred alerts that are no longer silenced
+func (s *SilenceStore) TakeDeferred(now time.Time) ([]DeferredAlert, error) {
+	var released []DeferredAlert
+	err := s.update(func(contents *silencesFile) error {
+		var stillDeferred []DeferredAlert
+		for _, deferred := range contents.Deferred {
+			if findActiveSilence(contents.Silences, deferred.Alert, now) != nil {
+				stillDeferred = append(stillDeferred, deferred)
+			} else {
+				released = append(released, deferred)
+			}
+		}
+		contents.Deferred = stillDeferred
+		return nil
+	})
+	return released, err
+}
+
+func findActiveSilence(silences []Silence, alert Alert, now time.Time) *Silence {
+	for i := range silences {
+		if silences[i].Matches(alert) && silences[i].ActiveAt(now) {
+			return &silences[i]
+		}
+	}
+	return nil
+}
+
+func (s *SilenceStore) update(change func(contents *silencesFile) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	contents, err := s.read()
+	if err != nil {
+		return err
+	}
+	if err := change(&contents); err != nil {
+		return err
+	}
+	return writeJSONFile(s.path, contents)
+}
+
+func (s *SilenceStore) read() (silencesFile, error) {
+	var contents silencesFile
+	err := readJSONFile(s.path, &contents)
+	return contents, err
+}
+
+func readJSONFile(path string, v interface{}) error {
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(contents, v); err != nil {
+		return fmt.Errorf("%s not parseable: %s", path, err)
+	}
+	return nil
+}
+
+// lockFile takes an exclusive lock on a file next to the given one, waiting
+// for other processes holding it. The file itself cannot be locked as
+// writeJSONFile replaces it.
+func lockFile(path string) (func(), error) {
+	lock, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX); err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("locking %s: %s", path, err)
+	}
+	return func() {
+		syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		lock.Close()
+	}, nil
+}
+
+// writeJSONFile replaces the file atomically so that concurrent readers never
+// see a partially written file
+func writeJSONFile(path string, v interface{}) error {
+	contents, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name())
+
+	if _, err := tempFile.Write(contents); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), path)
+}
@@ -0,0 +1,180 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Silences", func() {
+	london, _ := time.LoadLocation("Europe/London")
+
+	Describe("ActiveAt", func() {
+		It("is active during a one-off window", func() {
+			silence := Silence{
+				StartsAt: time.Date(2016, 10, 1, 22, 0, 0, 0, time.UTC),
+				EndsAt:   time.Date(2016, 10, 2, 2, 0, 0, 0, time.UTC),
+			}
+
+			Expect(silence.ActiveAt(time.Date(2016, 10, 1, 21, 59, 0, 0, time.UTC))).To(BeFalse())
+			Expect(silence.ActiveAt(time.Date(2016, 10, 2, 1, 0, 0, 0, time.UTC))).To(BeTrue())
+			Expect(silence.ActiveAt(time.Date(2016, 10, 2, 2, 0, 0, 0, time.UTC))).To(BeFalse())
+		})
+
+		It("repeats a daily window at the same wall clock time across DST changes", func() {
+			silence := Silence{
+				StartsAt:   time.Date(2016, 10, 1, 23, 0, 0, 0, london),
+				EndsAt:     time.Date(2016, 10, 2, 1, 0, 0, 0, london),
+				Timezone:   "Europe/London",
+				Recurrence: RecurrenceDaily,
+			}
+
+			Expect(silence.ActiveAt(time.Date(2016, 11, 15, 0, 30, 0, 0, london))).To(BeTrue())
+			Expect(silence.ActiveAt(time.Date(2016, 11, 15, 23, 30, 0, 0, london))).To(BeTrue())
+			Expect(silence.ActiveAt(time.Date(2016, 11, 15, 1, 30, 0, 0, london))).To(BeFalse())
+			Expect(silence.ActiveAt(time.Date(2016, 11, 15, 22, 30, 0, 0, london))).To(BeFalse())
+		})
+
+		It("repeats a weekly window on the same weekday", func() {
+			saturday := time.Date(2016, 10, 1, 10, 0, 0, 0, time.UTC)
+			silence := Silence{
+				StartsAt:   saturday,
+				EndsAt:     saturday.Add(2 * time.Hour),
+				Recurrence: RecurrenceWeekly,
+			}
+
+			Expect(silence.ActiveAt(saturday.AddDate(0, 0, 7).Add(time.Hour))).To(BeTrue())
+			Expect(silence.ActiveAt(saturday.AddDate(0, 0, 8).Add(time.Hour))).To(BeFalse())
+		})
+
+		It("is not active once expired", func() {
+			expiredAt := time.Date(2016, 10, 1, 23, 0, 0, 0, time.UTC)
+			silence := Silence{
+				StartsAt:  time.Date(2016, 10, 1, 22, 0, 0, 0, time.UTC),
+				EndsAt:    time.Date(2016, 10, 2, 2, 0, 0, 0, time.UTC),
+				ExpiredAt: &expiredAt,
+			}
+
+			Expect(silence.ActiveAt(expiredAt.Add(-time.Minute))).To(BeTrue())
+			Expect(silence.ActiveAt(expiredAt)).To(BeFalse())
+		})
+	})
+
+	It("matches alerts on product, service instance and labels", func() {
+		silence := Silence{Product: "redis", ServiceInstance: "instance-1", Labels: map[string]string{"az": "z1"}}
+
+		Expect(silence.Matches(Alert{Product: "redis", ServiceInstanceID: "instance-1", Labels: map[string]string{"az": "z1"}})).To(BeTrue())
+		Expect(silence.Matches(Alert{Product: "redis", ServiceInstanceID: "instance-1"})).To(BeFalse())
+		Expect(silence.Matches(Alert{Product: "mysql", ServiceInstanceID: "instance-1", Labels: map[string]string{"az": "z1"}})).To(BeFalse())
+	})
+
+	Describe("SilenceStore", func() {
+		var (
+			dir   string
+			store *SilenceStore
+			now   = time.Date(2016, 10, 1, 22, 30, 0, 0, time.UTC)
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "silences")
+			Expect(err).NotTo(HaveOccurred())
+			store = NewSilenceStore(filepath.Join(dir, "silences.json"))
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		})
+
+		It("creates, lists and expires silences", func() {
+			silence, err := store.Create(Silence{
+				Product:  "redis",
+				Action:   SilenceActionDrop,
+				StartsAt: now.Add(-time.Hour),
+				EndsAt:   now.Add(time.Hour),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(silence.ID).NotTo(BeEmpty())
+
+			active, err := store.Active(Alert{Product: "redis"}, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(active.ID).To(Equal(silence.ID))
+
+			Expect(store.Expire(silence.ID, now)).To(Succeed())
+			Expect(store.Active(Alert{Product: "redis"}, now)).To(BeNil())
+
+			silences, err := store.List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(silences).To(HaveLen(1))
+			Expect(silences[0].Expired(now)).To(BeTrue())
+		})
+
+		It("rejects invalid silences", func() {
+			_, err := store.Create(Silence{Action: "ignore", StartsAt: now, EndsAt: now.Add(time.Hour)})
+			Expect(err).To(MatchError("invalid silence: action must be 'drop' or 'defer'"))
+
+			_, err = store.Create(Silence{Action: SilenceActionDrop, StartsAt: now, EndsAt: now.Add(25 * time.Hour), Recurrence: RecurrenceDaily})
+			Expect(err).To(MatchError("invalid silence: a daily window must not be longer than 24h0m0s"))
+		})
+
+		It("releases deferred alerts once their silence has ended", func() {
+			silence, err := store.Create(Silence{
+				Action:   SilenceActionDefer,
+				StartsAt: now.Add(-time.Hour),
+				EndsAt:   now.Add(time.Hour),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(store.Defer(Alert{Product: "redis"}, silence.ID, now)).To(Succeed())
+
+			Expect(store.TakeDeferred(now)).To(BeEmpty())
+
+			released, err := store.TakeDeferred(now.Add(2 * time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(released).To(HaveLen(1))
+			Expect(released[0].Alert.Product).To(Equal("redis"))
+
+			Expect(store.TakeDeferred(now.Add(2 * time.Hour))).To(BeEmpty())
+		})
+
+		It("records when deferred alerts occurred", func() {
+			config := Config{SilencesFile: filepath.Join(dir, "silences.json")}
+			_, err := store.Create(Silence{Action: SilenceActionDefer, StartsAt: time.Now().Add(-time.Hour), EndsAt: time.Now().Add(time.Hour)})
+			Expect(err).NotTo(HaveOccurred())
+
+			before := time.Now()
+			Expect(New(config, log.New(GinkgoWriter, "", 0)).SendAlert(Alert{Product: "redis"})).To(Succeed())
+
+			released, err := store.TakeDeferred(time.Now().Add(2 * time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(released).To(HaveLen(1))
+			Expect(released[0].Alert.OccurredAt).To(BeTemporally(">=", before))
+		})
+
+		It("keeps every alert deferred concurrently by separate stores on the same file", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					Expect(NewSilenceStore(filepath.Join(dir, "silences.json")).Defer(Alert{Product: "redis"}, "silence", now)).To(Succeed())
+				}()
+			}
+			wg.Wait()
+
+			Expect(store.TakeDeferred(now)).To(HaveLen(20))
+		})
+	})
+})
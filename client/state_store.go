@@ -0,0 +1,157 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// StateStore records which alerts, identified by fingerprint, have been
+// acknowledged. An acknowledgement lasts until the alert is resolved, so that
+// the next time the alert fires it is escalated again.
+type StateStore interface {
+	Acknowledge(fingerprint string, at time.Time) error
+	Acknowledged(fingerprint string) (bool, error)
+	Resolve(fingerprint string) (bool, error)
+}
+
+type MemoryStateStore struct {
+	mutex        sync.Mutex
+	acknowledged map[string]time.Time
+	firing       map[string]time.Time
+}
+
+func NewMemoryStateStore() *MemoryStateStore {
+	return &MemoryStateStore{acknowledged: map[string]time.Time{}, firing: map[string]time.Time{}}
+}
+
+func (s *MemoryStateStore) Acknowledge(fingerprint string, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.acknowledged[fingerprint] = at
+	return nil
+}
+
+func (s *MemoryStateStore) Acknowledged(fingerprint string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, ok := s.acknowledged[fingerprint]
+	return ok, nil
+}
+
+func (s *MemoryStateStore) Fire(fingerprint string, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.firing[fingerprint] = at
+	return nil
+}
+
+// Firing reports whether an alert has been sent and not resolved yet
+func (s *FileStateStore) Firing(fingerprint string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var state stateFile
+	if err := readJSONFile(s.path, &state); err != nil {
+		return false, err
+	}
+	_, ok := state.Firing[fingerprint]
+	return ok, nil
+}
+
+// Resolve forgets a firing alert and its acknowledgement, and reports whether
+// it was firing
+func (s *MemoryStateStore) Resolve(fingerprint string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, firing := s.firing[fingerprint]
+	delete(s.firing, fingerprint)
+	delete(s.acknowledged, fingerprint)
+	return firing, nil
+}
+
+// FileStateStore keeps alert state in a local JSON file so that it survives restarts
+type FileStateStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+type stateFile struct {
+	Acknowledged map[string]time.Time `json:"acknowledged"`
+	Firing       map[string]time.Time `json:"firing,omitempty"`
+}
+
+func NewFileStateStore(path string) *FileStateStore {
+	return &FileStateStore{path: path}
+}
+
+func (s *FileStateStore) Acknowledge(fingerprint string, at time.Time) error {
+	return s.update(func(state *stateFile) {
+		if state.Acknowledged == nil {
+			state.Acknowledged = map[string]time.Time{}
+		}
+		state.Acknowledged[fingerprint] = at
+	})
+}
+
+func (s *FileStateStore) Acknowledged(fingerprint string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var state stateFile
+	if err := readJSONFile(s.path, &state); err != nil {
+		return false, err
+	}
+	_, ok := state.Acknowledged[fingerprint]
+	return ok, nil
+}
+
+// Fire records that an alert has been sent and not resolved yet, so that a
+// later process knows to send the resolution
+func (s *FileStateStore) Fire(fingerprint string, at time.Time) error {
+	return s.update(func(state *stateFile) {
+		if state.Firing == nil {
+			state.Firing = map[string]time.Time{}
+		}
+		state.Firing[fingerprint] = at
+	})
+}
+
+// Resolve forgets a firing alert and its acknowledgement, and reports whether
+// it was firing
+func (s *FileStateStore) Resolve(fingerprint string) (bool, error) {
+	var firing bool
+	err := s.update(func(state *stateFile) {
+		_, firing = state.Firing[fingerprint]
+		delete(state.Firing, fingerprint)
+		delete(state.Acknowledged, fingerprint)
+	})
+	return firing, err
+}
+
+func (s *FileStateStore) update(change func(state *stateFile)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	unlock, err := lockFile(s.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	var state stateFile
+	if err := readJSONFile(s.path, &state); err != nil {
+		return err
+	}
+	change(&state)
+	return writeJSONFile(s.path, state)
+}
@@ -0,0 +1,44 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"net/http"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// StructuredLogger is what the client logs requests to when one is set with
+// WithStructuredLogger. A lager.Logger is one.
+type StructuredLogger interface {
+	Debug(action string, data ...lager.Data)
+	Info(action string, data ...lager.Data)
+	Error(action string, err error, data ...lager.Data)
+}
+
+// WithStructuredLogger logs each request to the CF API, UAA and the
+// notifications service as a debug message, and failed requests as errors,
+// with the request label, URL, attempt and status as fields. Retries and
+// giving up are then no longer written to the *log.Logger the client was
+// created with. Credentials are redacted from everything logged.
+func (c *ServiceAlertsClient) WithStructuredLogger(logger StructuredLogger) *ServiceAlertsClient {
+	c.httpClient.structuredLogger = logger
+	return c
+}
+
+func (r *RetryHTTPClient) requestData(label string, req *http.Request, attempt int, response *http.Response) lager.Data {
+	data := lager.Data{
+		"label":   label,
+		"method":  req.Method,
+		"url":     r.redactor.redact(req.URL.String()),
+		"attempt": attempt,
+	}
+	if response != nil {
+		data["status"] = response.StatusCode
+	}
+	return data
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	DefaultMaxContentBytes = 64 * 1024
+	elisionMarkerFormat    = "\n\n[... %d of %d bytes omitted ...]\n\n"
+)
+
+// TruncateContent cuts content down to at most maxBytes, keeping its start
+// and its end, which for logs is usually where the error is, around a marker
+// saying how much was removed. A maxBytes of zero means
+// DefaultMaxContentBytes.
+func TruncateContent(content string, maxBytes int) string {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxContentBytes
+	}
+	return elide(content, maxBytes)
+}
+
+// elide keeps two thirds of maxBytes from the start of content and one third
+// from the end, cutting at line breaks when there is one close by and never
+// inside a character. When maxBytes has no room for the marker only the start
+// is kept.
+func elide(content string, maxBytes int) string {
+	if len(content) <= maxBytes {
+		return content
+	}
+
+	available := maxBytes - len(fmt.Sprintf(elisionMarkerFormat, len(content), len(content)))
+	if available <= 0 {
+		return content[:headEnd(content, maxBytes)]
+	}
+
+	head := content[:headEnd(content, available*2/3)]
+	tail := content[tailStart(content, len(content)-(available-available*2/3)):]
+	omitted := len(content) - len(head) - len(tail)
+	return head + fmt.Sprintf(elisionMarkerFormat, omitted, len(content)) + tail
+}
+
+// headEnd is where to cut content to keep at most n bytes of its start
+func headEnd(content string, n int) int {
+	if i := strings.LastIndex(content[:n], "\n"); i >= n/2 {
+		return i
+	}
+	for n > 0 && !utf8.RuneStart(content[n]) {
+		n--
+	}
+	return n
+}
+
+// tailStart is where to start content to keep at most len(content)-start
+// bytes of its end
+func tailStart(content string, start int) int {
+	remaining := len(content) - start
+	if i := strings.Index(content[start:], "\n"); i >= 0 && i < remaining/2 {
+		return start + i + 1
+	}
+	for start < len(content) && !utf8.RuneStart(content[start]) {
+		start++
+	}
+	return start
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TruncateContent", func() {
+	It("leaves content within the limit alone", func() {
+		Expect(TruncateContent("short", 5)).To(Equal("short"))
+	})
+
+	It("keeps the start and the end and says how much was omitted", func() {
+		content := strings.Repeat("a", 50) + strings.Repeat("b", 50)
+		Expect(TruncateContent(content, 80)).To(Equal(strings.Repeat("a", 28) + "\n\n[... 58 of 100 bytes omitted ...]\n\n" + strings.Repeat("b", 14)))
+	})
+
+	It("cuts at line breaks close to the limit", func() {
+		var lines []string
+		for i := 0; i < 20; i++ {
+			lines = append(lines, fmt.Sprintf("line %02d", i))
+		}
+		truncated := TruncateContent(strings.Join(lines, "\n"), 100)
+		Expect(len(truncated)).To(BeNumerically("<=", 100))
+		Expect(truncated).To(Equal("line 00\nline 01\nline 02\nline 03\nline 04\n\n[... 105 of 159 bytes omitted ...]\n\nline 18\nline 19"))
+	})
+
+	It("does not split multi-byte characters", func() {
+		truncated := TruncateContent(strings.Repeat("é", 50), 60)
+		Expect(utf8.ValidString(truncated)).To(BeTrue())
+		Expect(len(truncated)).To(BeNumerically("<=", 60))
+	})
+
+	It("only keeps the start when there is no room for the marker", func() {
+		Expect(TruncateContent("0123456789", 4)).To(Equal("0123"))
+	})
+
+	It("uses the default limit when none is configured", func() {
+		content := strings.Repeat("x", DefaultMaxContentBytes+1)
+		truncated := TruncateContent(content, 0)
+		Expect(len(truncated)).To(BeNumerically("<=", DefaultMaxContentBytes))
+		Expect(truncated).To(ContainSubstring("bytes omitted"))
+	})
+})
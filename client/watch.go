@@ -0,0 +1,437 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+const (
+	defaultWatchPollInterval = time.Second
+	defaultWatchSubject      = `{{.Rule}} in {{.File}}`
+
+	// watchReadChunkSize is how much of a file is read at a time, so that a
+	// large file that appears later is not read into memory at once
+	watchReadChunkSize = 64 * 1024
+	// maxWatchLineBytes is how much of a line is kept; the rest is dropped
+	maxWatchLineBytes = 64 * 1024
+)
+
+// LogWatcher follows log files, including across rotation and truncation, and
+// sends an alert for lines matching its rules
+type LogWatcher struct {
+	patterns   []string
+	interval   time.Duration
+	rules      []*watchRule
+	dispatcher AlertDispatcher
+	clock      clock.Clock
+	logger     *log.Logger
+
+	files   map[string]*tailedFile
+	started bool
+}
+
+type watchRule struct {
+	WatchRule
+	pattern  *regexp.Regexp
+	subject  *template.Template
+	severity Severity
+	throttle time.Duration
+}
+
+type tailedFile struct {
+	path      string
+	file      *os.File
+	info      os.FileInfo
+	partial   string
+	before    []string
+	pending   []*pendingMatch
+	throttles map[*watchRule]*throttleState
+}
+
+// throttleState is kept per file and rule, so that matches in one file do not
+// hold back alerts for the same rule in another
+type throttleState struct {
+	lastSent   time.Time
+	suppressed int
+}
+
+type pendingMatch struct {
+	rule       *watchRule
+	file       string
+	line       string
+	groups     []string
+	before     []string
+	after      []string
+	suppressed int
+	polls      int
+	matchedAt  time.Time
+}
+
+// WatchedLine is what rule subject templates are executed against
+type WatchedLine struct {
+	Rule  string
+	File  string
+	Line  string
+	Match []string
+}
+
+func NewLogWatcher(config Watch, dispatcher AlertDispatcher, clock clock.Clock, logger *log.Logger) (*LogWatcher, error) {
+	if len(config.Files) == 0 {
+		return nil, ConfigError{Section: "watch", Err: errors.New("files must be set")}
+	}
+	if config.PollIntervalSeconds < 0 {
+		return nil, ConfigError{Section: "watch", Err: errors.New("poll_interval_seconds must not be negative")}
+	}
+
+	watcher := &LogWatcher{
+		patterns:   config.Files,
+		interval:   defaultWatchPollInterval,
+		dispatcher: dispatcher,
+		clock:      clock,
+		logger:     logger,
+		files:      map[string]*tailedFile{},
+	}
+	if config.PollIntervalSeconds != 0 {
+		watcher.interval = time.Duration(config.PollIntervalSeconds) * time.Second
+	}
+
+	for _, rule := range config.Rules {
+		parsed, err := parseWatchRule(rule)
+		if err != nil {
+			return nil, ConfigError{Section: "watch", Err: err}
+		}
+		watcher.rules = append(watcher.rules, parsed)
+	}
+	if len(watcher.rules) == 0 {
+		return nil, ConfigError{Section: "watch", Err: errors.New("rules must be set")}
+	}
+	return watcher, nil
+}
+
+func parseWatchRule(rule WatchRule) (*watchRule, error) {
+	if rule.Name == "" || rule.Product == "" {
+		return nil, errors.New("every rule needs a name and a product")
+	}
+	if rule.ThrottleSeconds < 0 || rule.ContextBefore < 0 || rule.ContextAfter < 0 {
+		return nil, fmt.Errorf("rule %s: throttle and context must not be negative", rule.Name)
+	}
+
+	pattern, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %s", rule.Name, err)
+	}
+
+	subjectText := rule.Subject
+	if subjectText == "" {
+		subjectText = defaultWatchSubject
+	}
+	subject, err := template.New(rule.Name).Option("missingkey=zero").Parse(subjectText)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %s", rule.Name, err)
+	}
+
+	severity := SeverityCritical
+	if rule.Severity != "" {
+		if severity, err = ParseSeverity(rule.Severity); err != nil {
+			return nil, fmt.Errorf("rule %s: %s", rule.Name, err)
+		}
+	}
+
+	return &watchRule{
+		WatchRule: rule,
+		pattern:   pattern,
+		subject:   subject,
+		severity:  severity,
+		throttle:  time.Duration(rule.ThrottleSeconds) * time.Second,
+	}, nil
+}
+
+// Poll reads what was written to the watched files since the last poll. Files
+// that exist on the first poll are read from their end, so old lines do not
+// raise alerts; files that appear later are read from the start.
+func (w *LogWatcher) Poll() {
+	for _, pattern := range w.patterns {
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			w.logger.Printf("Invalid watch pattern %s: %s", pattern, err)
+			continue
+		}
+		for _, path := range paths {
+			if _, ok := w.files[path]; !ok {
+				w.files[path] = &tailedFile{path: path, throttles: map[*watchRule]*throttleState{}}
+				w.open(w.files[path], !w.started)
+			}
+		}
+	}
+	w.started = true
+
+	for _, tailed := range w.files {
+		w.follow(tailed)
+		w.flush(tailed)
+	}
+}
+
+// Run polls the files every poll interval until stop is closed, then sends
+// any alerts still waiting for context lines
+func (w *LogWatcher) Run(stop <-chan struct{}) {
+	w.Poll()
+
+	ticker := w.clock.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			w.Poll()
+		case <-stop:
+			for _, tailed := range w.files {
+				w.sendPending(tailed, true)
+				if tailed.file != nil {
+					tailed.file.Close()
+				}
+			}
+			return
+		}
+	}
+}
+
+func (w *LogWatcher) open(tailed *tailedFile, fromEnd bool) {
+	file, err := os.Open(tailed.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			w.logger.Printf("Failed to open %s: %s", tailed.path, err)
+		}
+		return
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		w.logger.Printf("Failed to open %s: %s", tailed.path, err)
+		return
+	}
+	if fromEnd {
+		if _, err := file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			w.logger.Printf("Failed to open %s: %s", tailed.path, err)
+			return
+		}
+	}
+
+	tailed.file, tailed.info, tailed.partial = file, info, ""
+}
+
+// follow reads new lines, first finishing the old file when the path now
+// refers to a different file, and starting over when the file was truncated
+func (w *LogWatcher) follow(tailed *tailedFile) {
+	if tailed.file == nil {
+		w.open(tailed, false)
+		if tailed.file == nil {
+			return
+		}
+	}
+
+	current, err := os.Stat(tailed.path)
+	if err != nil && !os.IsNotExist(err) {
+		w.logger.Printf("Failed to check %s: %s", tailed.path, err)
+		return
+	}
+
+	switch {
+	case current == nil:
+		w.read(tailed)
+	case !os.SameFile(current, tailed.info):
+		w.read(tailed)
+		w.endPartialLine(tailed)
+		tailed.file.Close()
+		w.open(tailed, false)
+		if tailed.file != nil {
+			w.read(tailed)
+		}
+	default:
+		offset, err := tailed.file.Seek(0, io.SeekCurrent)
+		if err == nil && current.Size() < offset {
+			w.logger.Printf("%s was truncated, reading it from the start", tailed.path)
+			tailed.file.Seek(0, io.SeekStart)
+			tailed.partial = ""
+		}
+		w.read(tailed)
+	}
+}
+
+func (w *LogWatcher) read(tailed *tailedFile) {
+	chunk := make([]byte, watchReadChunkSize)
+	for {
+		n, err := tailed.file.Read(chunk)
+		w.readLines(tailed, string(chunk[:n]))
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			w.logger.Printf("Failed to read %s: %s", tailed.path, err)
+			return
+		}
+	}
+}
+
+// readLines processes the complete lines in data and keeps the rest for the
+// next read, cutting lines down to maxWatchLineBytes
+func (w *LogWatcher) readLines(tailed *tailedFile, data string) {
+	for {
+		end := strings.Index(data, "\n")
+		if end < 0 {
+			tailed.partial = limitLine(tailed.partial + data)
+			return
+		}
+		line := limitLine(tailed.partial + data[:end])
+		tailed.partial, data = "", data[end+1:]
+		w.process(tailed, strings.TrimSuffix(line, "\r"))
+	}
+}
+
+func limitLine(line string) string {
+	if len(line) <= maxWatchLineBytes {
+		return line
+	}
+	return line[:headEnd(line, maxWatchLineBytes)]
+}
+
+// endPartialLine treats an unterminated last line of a rotated file as complete
+func (w *LogWatcher) endPartialLine(tailed *tailedFile) {
+	if tailed.partial != "" {
+		w.process(tailed, tailed.partial)
+		tailed.partial = ""
+	}
+}
+
+func (w *LogWatcher) process(tailed *tailedFile, line string) {
+	for _, match := range tailed.pending {
+		if len(match.after) < match.rule.ContextAfter {
+			match.after = append(match.after, line)
+		}
+	}
+
+	now := w.clock.Now()
+	for _, rule := range w.rules {
+		groups := rule.pattern.FindStringSubmatch(line)
+		if groups == nil {
+			continue
+		}
+		throttle, ok := tailed.throttles[rule]
+		if !ok {
+			throttle = &throttleState{}
+			tailed.throttles[rule] = throttle
+		}
+		if rule.throttle > 0 && !throttle.lastSent.IsZero() && now.Sub(throttle.lastSent) < rule.throttle {
+			throttle.suppressed++
+			continue
+		}
+
+		throttle.lastSent = now
+		tailed.pending = append(tailed.pending, &pendingMatch{
+			rule:       rule,
+			file:       tailed.path,
+			line:       line,
+			groups:     groups,
+			before:     lastLines(tailed.before, rule.ContextBefore),
+			suppressed: throttle.suppressed,
+			matchedAt:  now,
+		})
+		throttle.suppressed = 0
+	}
+
+	tailed.before = append(tailed.before, line)
+	if max := w.maxContextBefore(); len(tailed.before) > max {
+		tailed.before = tailed.before[len(tailed.before)-max:]
+	}
+}
+
+// flush sends matches that have all their context lines, or that have waited
+// a whole poll interval for them
+func (w *LogWatcher) flush(tailed *tailedFile) {
+	w.sendPending(tailed, false)
+	for _, match := range tailed.pending {
+		match.polls++
+	}
+}
+
+func (w *LogWatcher) sendPending(tailed *tailedFile, all bool) {
+	var waiting []*pendingMatch
+	for _, match := range tailed.pending {
+		if !all && len(match.after) < match.rule.ContextAfter && match.polls == 0 {
+			waiting = append(waiting, match)
+			continue
+		}
+		w.send(match)
+	}
+	tailed.pending = waiting
+}
+
+func (w *LogWatcher) send(match *pendingMatch) {
+	var subject bytes.Buffer
+	data := WatchedLine{Rule: match.rule.Name, File: match.file, Line: match.line, Match: match.groups}
+	if err := match.rule.subject.Execute(&subject, data); err != nil {
+		w.logger.Printf("Failed to render subject of rule %s: %s", match.rule.Name, err)
+		subject.Reset()
+		subject.WriteString(match.rule.Name)
+	}
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "Line matching rule %s in %s:\n\n", match.rule.Name, match.file)
+	for _, line := range match.before {
+		fmt.Fprintln(&content, line)
+	}
+	fmt.Fprintln(&content, match.line)
+	for _, line := range match.after {
+		fmt.Fprintln(&content, line)
+	}
+	if match.suppressed > 0 {
+		fmt.Fprintf(&content, "\n%d earlier matching lines were not sent because of the throttle.\n", match.suppressed)
+	}
+
+	alert := Alert{
+		Product:           match.rule.Product,
+		ServiceInstanceID: match.rule.ServiceInstance,
+		Subject:           strings.TrimSpace(subject.String()),
+		Content:           content.String(),
+		Severity:          match.rule.severity,
+		Labels:            map[string]string{"rule": match.rule.Name, "file": match.file},
+		OccurredAt:        match.matchedAt,
+	}
+	if err := w.dispatcher.Dispatch(alert); err != nil {
+		w.logger.Printf("Failed to send alert for rule %s: %s", match.rule.Name, err)
+	}
+}
+
+func (w *LogWatcher) maxContextBefore() int {
+	max := 0
+	for _, rule := range w.rules {
+		if rule.ContextBefore > max {
+			max = rule.ContextBefore
+		}
+	}
+	return max
+}
+
+func lastLines(lines []string, n int) []string {
+	if n > len(lines) {
+		n = len(lines)
+	}
+	return append([]string{}, lines[len(lines)-n:]...)
+}
@@ -0,0 +1,208 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LogWatcher", func() {
+	var (
+		dir        string
+		logPath    string
+		dispatcher *fakeAlertDispatcher
+		clock      *fakeClock
+		config     Watch
+		watcher    *LogWatcher
+		logger     = log.New(GinkgoWriter, "", 0)
+	)
+
+	appendLog := func(text string) {
+		file, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		Expect(err).NotTo(HaveOccurred())
+		defer file.Close()
+		_, err = file.WriteString(text)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	subjects := func() []string {
+		var subjects []string
+		for _, alert := range dispatcher.dispatched {
+			subjects = append(subjects, alert.Subject)
+		}
+		return subjects
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "watch")
+		Expect(err).NotTo(HaveOccurred())
+		logPath = filepath.Join(dir, "redis.log")
+		appendLog("ERROR before the watcher started\n")
+
+		dispatcher = &fakeAlertDispatcher{}
+		clock = newFakeClock(time.Date(2016, 10, 1, 0, 0, 0, 0, time.UTC))
+		config = Watch{
+			Files: []string{filepath.Join(dir, "*.log")},
+			Rules: []WatchRule{{
+				Name:     "errors",
+				Pattern:  `ERROR (.*)`,
+				Product:  "redis",
+				Subject:  `Redis error: {{index .Match 1}}`,
+				Severity: "warning",
+			}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		watcher, err = NewLogWatcher(config, dispatcher, clock, logger)
+		Expect(err).NotTo(HaveOccurred())
+		watcher.Poll()
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("requires rules to have a name and product", func() {
+		config.Rules[0].Product = ""
+		_, err := NewLogWatcher(config, dispatcher, clock, logger)
+		Expect(err).To(MatchError("invalid watch config: every rule needs a name and a product"))
+	})
+
+	It("ignores lines written before it started", func() {
+		Expect(dispatcher.dispatched).To(BeEmpty())
+	})
+
+	It("sends an alert for new matching lines", func() {
+		appendLog("INFO all good\nERROR out of memory\n")
+		watcher.Poll()
+
+		Expect(dispatcher.dispatched).To(HaveLen(1))
+		alert := dispatcher.dispatched[0]
+		Expect(alert.Product).To(Equal("redis"))
+		Expect(alert.Subject).To(Equal("Redis error: out of memory"))
+		Expect(alert.Severity).To(Equal(SeverityWarning))
+		Expect(alert.Labels).To(Equal(map[string]string{"rule": "errors", "file": logPath}))
+		Expect(alert.Content).To(Equal("Line matching rule errors in " + logPath + ":\n\nERROR out of memory\n"))
+	})
+
+	It("waits for a line to be complete", func() {
+		appendLog("ERROR out of")
+		watcher.Poll()
+		Expect(dispatcher.dispatched).To(BeEmpty())
+
+		appendLog(" memory\n")
+		watcher.Poll()
+		Expect(subjects()).To(Equal([]string{"Redis error: out of memory"}))
+	})
+
+	It("follows the file when it is rotated", func() {
+		appendLog("ERROR one\n")
+		Expect(os.Rename(logPath, logPath+".1")).To(Succeed())
+		appendLog("ERROR two\n")
+		watcher.Poll()
+
+		Expect(subjects()).To(Equal([]string{"Redis error: one", "Redis error: two"}))
+	})
+
+	It("starts over when the file is truncated", func() {
+		appendLog("INFO a long line that makes the file longer\n")
+		watcher.Poll()
+		Expect(os.Truncate(logPath, 0)).To(Succeed())
+		appendLog("ERROR after truncation\n")
+		watcher.Poll()
+
+		Expect(subjects()).To(Equal([]string{"Redis error: after truncation"}))
+	})
+
+	It("reads files that appear later from the start", func() {
+		logPath = filepath.Join(dir, "new.log")
+		appendLog("ERROR in a new file\n")
+		watcher.Poll()
+
+		Expect(subjects()).To(Equal([]string{"Redis error: in a new file"}))
+	})
+
+	It("reads large files in chunks without splitting lines", func() {
+		logPath = filepath.Join(dir, "large.log")
+		appendLog(strings.Repeat("INFO padding\n", 3*watchReadChunkSize/len("INFO padding\n")) + "ERROR across a chunk boundary\n")
+		watcher.Poll()
+
+		Expect(subjects()).To(Equal([]string{"Redis error: across a chunk boundary"}))
+	})
+
+	It("keeps only the start of very long lines", func() {
+		appendLog("ERROR " + strings.Repeat("x", 2*maxWatchLineBytes) + "\nERROR next\n")
+		watcher.Poll()
+
+		Expect(dispatcher.dispatched).To(HaveLen(2))
+		Expect(dispatcher.dispatched[0].Subject).To(HaveLen(len("Redis error: ") + maxWatchLineBytes - len("ERROR ")))
+		Expect(subjects()[1]).To(Equal("Redis error: next"))
+	})
+
+	Context("with context lines", func() {
+		BeforeEach(func() {
+			config.Rules[0].ContextBefore = 1
+			config.Rules[0].ContextAfter = 2
+		})
+
+		It("includes the lines around the match", func() {
+			appendLog("one\ntwo\nERROR three\nfour\n")
+			watcher.Poll()
+			Expect(dispatcher.dispatched).To(BeEmpty())
+
+			appendLog("five\nsix\n")
+			watcher.Poll()
+			Expect(dispatcher.dispatched).To(HaveLen(1))
+			Expect(dispatcher.dispatched[0].Content).To(HaveSuffix("two\nERROR three\nfour\nfive\n"))
+		})
+
+		It("does not wait more than one poll for the lines after the match", func() {
+			appendLog("ERROR three\n")
+			watcher.Poll()
+			watcher.Poll()
+			Expect(dispatcher.dispatched).To(HaveLen(1))
+		})
+	})
+
+	Context("with a throttle", func() {
+		BeforeEach(func() {
+			config.Rules[0].ThrottleSeconds = 60
+		})
+
+		It("sends at most one alert per throttle period and counts the rest", func() {
+			appendLog("ERROR one\nERROR two\nERROR three\n")
+			watcher.Poll()
+			Expect(subjects()).To(Equal([]string{"Redis error: one"}))
+
+			clock.Increment(time.Minute)
+			appendLog("ERROR four\n")
+			watcher.Poll()
+			Expect(subjects()).To(Equal([]string{"Redis error: one", "Redis error: four"}))
+			Expect(dispatcher.dispatched[1].Content).To(ContainSubstring("2 earlier matching lines were not sent because of the throttle."))
+		})
+
+		It("throttles each file separately", func() {
+			appendLog("ERROR one\n")
+			logPath = filepath.Join(dir, "sentinel.log")
+			appendLog("ERROR two\n")
+			watcher.Poll()
+
+			Expect(subjects()).To(ConsistOf("Redis error: one", "Redis error: two"))
+		})
+	})
+})
@@ -0,0 +1,145 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pivotal-cf/service-alerts-client/client"
+)
+
+const maxBatchLineBytes = 1024 * 1024
+
+type batchResult struct {
+	Line        int    `json:"line"`
+	Status      string `json:"status"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type batchAlert struct {
+	line  int
+	alert client.Alert
+}
+
+func batch(args []string) {
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	configFilePath := flags.String("config", "", "config file path")
+	inputPath := flags.String("file", "-", "file to read newline-delimited JSON alerts from, - for stdin")
+	concurrency := flags.Int("concurrency", 4, "maximum number of alerts sent at the same time")
+	must(flags.Parse(args))
+
+	if *concurrency < 1 {
+		mustNot(errors.New("concurrency must be at least 1"))
+	}
+
+	input := os.Stdin
+	if *inputPath != "-" {
+		file, err := os.Open(*inputPath)
+		mustNot(err)
+		defer file.Close()
+		input = file
+	}
+
+	logFlags := log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC
+	logger := log.New(os.Stderr, "[service alerts client] ", logFlags)
+
+	config := loadConfig(*configFilePath)
+	dispatcher, err := client.NewDispatcher(client.New(config, logger))
+	mustNot(err)
+
+	sent, failed := sendBatch(dispatcher, config, input, os.Stdout, *concurrency)
+	switch {
+	case failed > 0 && sent == 0:
+		os.Exit(batchExitAllFailed)
+	case failed > 0:
+		os.Exit(batchExitSomeFailed)
+	}
+}
+
+// sendBatch reports alerts that were dispatched without error but sent to no
+// space as silenced, as batch mode does not collect digests
+func sendBatch(dispatcher *client.Dispatcher, config client.Config, input io.Reader, output io.Writer, concurrency int) (sent, failed int) {
+	alerts := make(chan batchAlert)
+	results := make(chan batchResult)
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for next := range alerts {
+				result := batchResult{Line: next.line, Status: "sent", Fingerprint: next.alert.Fingerprint()}
+				receipts, err := dispatcher.DispatchWithReceipts(limitContent(next.alert, config))
+				switch {
+				case err != nil:
+					result.Status, result.Error = "failed", err.Error()
+				case len(receipts) == 0:
+					result.Status = "silenced"
+				}
+				results <- result
+			}
+		}()
+	}
+
+	go func() {
+		readBatch(input, alerts, results)
+		close(alerts)
+		workers.Wait()
+		close(results)
+	}()
+
+	encoder := json.NewEncoder(output)
+	for result := range results {
+		switch result.Status {
+		case "sent":
+			sent++
+		case "silenced":
+		default:
+			failed++
+		}
+		encoder.Encode(result)
+	}
+	return sent, failed
+}
+
+func readBatch(input io.Reader, alerts chan<- batchAlert, results chan<- batchResult) {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), maxBatchLineBytes)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		alert := client.Alert{Severity: client.SeverityCritical}
+		if err := json.Unmarshal([]byte(text), &alert); err != nil {
+			results <- batchResult{Line: line, Status: "invalid", Error: "alert not parseable: " + err.Error()}
+			continue
+		}
+		if err := alert.Validate(); err != nil {
+			results <- batchResult{Line: line, Status: "invalid", Error: err.Error()}
+			continue
+		}
+		alerts <- batchAlert{line: line, alert: alert}
+	}
+
+	if err := scanner.Err(); err != nil {
+		results <- batchResult{Line: line + 1, Status: "invalid", Error: "failed to read input: " + err.Error()}
+	}
+}
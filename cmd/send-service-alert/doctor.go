@@ -0,0 +1,73 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pivotal-cf/service-alerts-client/client"
+)
+
+const defaultDoctorTimeoutSeconds = 10
+
+func doctor(args []string) {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configFilePath := flags.String("config", "", "config file path")
+	timeoutSeconds := flags.Int("timeout", defaultDoctorTimeoutSeconds, "seconds to keep retrying each check")
+	verbose := flags.Bool("verbose", false, "log requests and retries to stderr")
+	must(flags.Parse(args))
+
+	// the templates are checked with the rest of the config by Diagnose, so
+	// only a config that cannot be read at all stops the checks here
+	config, err := readConfig(*configFilePath)
+	if err != nil {
+		hint := "fix the config file, see the README for its format"
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			hint = "check the -config path and that the file can be read"
+		}
+		printDiagnostic(client.Diagnostic{Check: "config", Status: client.DiagnosticFail, Detail: err.Error(), Hint: hint})
+		os.Exit(1)
+	}
+	config.GlobalTimeoutSeconds = *timeoutSeconds
+
+	logOutput := ioutil.Discard
+	if *verbose {
+		logOutput = os.Stderr
+	}
+	logFlags := log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC
+	logger := log.New(logOutput, "[service alerts client] ", logFlags)
+
+	failed := false
+	for _, diagnostic := range client.New(config, logger).Diagnose() {
+		printDiagnostic(diagnostic)
+		if diagnostic.Status != client.DiagnosticPass {
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func printDiagnostic(diagnostic client.Diagnostic) {
+	line := fmt.Sprintf("[%s] %s", strings.ToUpper(string(diagnostic.Status)), diagnostic.Check)
+	if diagnostic.Detail != "" {
+		line += ": " + diagnostic.Detail
+	}
+	fmt.Println(line)
+	if diagnostic.Hint != "" {
+		fmt.Printf("       hint: %s\n", diagnostic.Hint)
+	}
+}
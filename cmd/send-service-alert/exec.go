@@ -0,0 +1,264 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pivotal-cf/service-alerts-client/client"
+)
+
+const (
+	execExitTimeout     = 124
+	execExitNotExecuted = 127
+)
+
+// maxTailLineBytes is how much of a single line of output is kept, from its
+// end, so that output without newlines cannot grow the buffer without limit
+const maxTailLineBytes = 4096
+
+const execUsage = "usage: send-service-alert exec -config <config file path> [alert flags] -- <command> [args]"
+
+func execCommand(args []string) {
+	flags := flag.NewFlagSet("exec", flag.ExitOnError)
+	configFilePath := flags.String("config", "", "config file path")
+	timeout := flags.Duration("timeout", 0, "kill the command after this long, for example 30m (optional)")
+	tailLines := flags.Int("tail-lines", 20, "number of lines of output to include in the alert")
+	resolveOnSuccess := flags.Bool("resolve-on-success", false, "send a resolved alert when the command succeeds after failing, requires state_file")
+	alert := alertFlags(flags)
+	must(flags.Parse(args))
+
+	command := flags.Args()
+	if len(command) == 0 {
+		log.Fatalln(execUsage)
+	}
+	if *tailLines < 0 {
+		mustNot(fmt.Errorf("-tail-lines must not be negative, got %d", *tailLines))
+	}
+
+	config := loadConfig(*configFilePath)
+	if *resolveOnSuccess && config.StateFile == "" {
+		mustNot(client.ConfigError{Err: errors.New("state_file is not set in the config")})
+	}
+
+	logFlags := log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC
+	logger := log.New(os.Stderr, "[service alerts client] ", logFlags)
+
+	// build the alert first, so that invalid alert flags fail before the
+	// command runs rather than losing its exit code afterwards
+	failure := alert()
+	if failure.Subject == "" {
+		failure.Subject = fmt.Sprintf("%s failed", strings.Join(command, " "))
+	}
+
+	result := runCommand(command, *timeout, *tailLines)
+	fingerprint := failure.Fingerprint()
+	alertsClient := client.New(config, logger)
+
+	if result.exitCode == 0 {
+		if !*resolveOnSuccess {
+			return
+		}
+		store := client.NewFileStateStore(config.StateFile)
+		firing, err := store.Firing(fingerprint)
+		if err != nil {
+			logger.Printf("Failed to read state file: %s", err)
+			return
+		}
+		if !firing {
+			return
+		}
+		// keep the alert firing until the resolution has been sent, so that a
+		// failed send is retried by the next successful run
+		resolved := failure
+		resolved.Resolved = true
+		resolved.Content = result.report(command, failure.Content)
+		if err := alertsClient.SendAlert(limitContent(resolved, config)); err != nil {
+			logger.Printf("Failed to send resolved alert: %s", err)
+			return
+		}
+		if _, err := store.Resolve(fingerprint); err != nil {
+			logger.Printf("Failed to write state file: %s", err)
+		}
+		return
+	}
+
+	failure.Content = result.report(command, failure.Content)
+	if err := alertsClient.SendAlert(limitContent(failure, config)); err != nil {
+		logger.Printf("Failed to send alert: %s", err)
+	} else if *resolveOnSuccess {
+		if err := client.NewFileStateStore(config.StateFile).Fire(fingerprint, time.Now()); err != nil {
+			logger.Printf("Failed to write state file: %s", err)
+		}
+	}
+	os.Exit(result.exitCode)
+}
+
+type commandResult struct {
+	status   string
+	exitCode int
+	duration time.Duration
+	output   *tailBuffer
+}
+
+// runCommand runs the command in its own process group with the same stdin,
+// stdout and stderr as this process, forwarding SIGINT and SIGTERM to the
+// group, and keeps the last lines of its output
+func runCommand(command []string, timeout time.Duration, tailLines int) commandResult {
+	output := newTailBuffer(tailLines)
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, output)
+	cmd.Stderr = io.MultiWriter(os.Stderr, output)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		return commandResult{
+			status:   fmt.Sprintf("could not be started: %s", err),
+			exitCode: execExitNotExecuted,
+			output:   output,
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	var timedOut <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timedOut = timer.C
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	for {
+		select {
+		case sig := <-signals:
+			syscall.Kill(-cmd.Process.Pid, sig.(syscall.Signal))
+		case <-timedOut:
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			<-done
+			return commandResult{
+				status:   fmt.Sprintf("timed out after %s", timeout),
+				exitCode: execExitTimeout,
+				duration: time.Since(started),
+				output:   output,
+			}
+		case err := <-done:
+			result := commandResult{status: "exited with 0", duration: time.Since(started), output: output}
+			if err != nil {
+				result.status, result.exitCode = exitStatus(err)
+			}
+			return result
+		}
+	}
+}
+
+// exitStatus describes how the command ended and picks the exit code to pass
+// on, following the shell convention of 128 plus the signal number
+func exitStatus(err error) (string, int) {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return err.Error(), 1
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok {
+		return exitErr.Error(), 1
+	}
+	if status.Signaled() {
+		return fmt.Sprintf("killed by signal %s", status.Signal()), 128 + int(status.Signal())
+	}
+	return fmt.Sprintf("exited with %d", status.ExitStatus()), status.ExitStatus()
+}
+
+func (r commandResult) report(command []string, content string) string {
+	var report bytes.Buffer
+	if content != "" {
+		fmt.Fprintf(&report, "%s\n\n", content)
+	}
+	fmt.Fprintf(&report, "Command: %s\n", strings.Join(command, " "))
+	fmt.Fprintf(&report, "Status: %s\n", r.status)
+	fmt.Fprintf(&report, "Duration: %s\n", r.duration.Round(time.Millisecond))
+	if lines := r.output.Lines(); len(lines) > 0 {
+		fmt.Fprintf(&report, "\nLast %d lines of output:\n%s\n", len(lines), strings.Join(lines, "\n"))
+	}
+	return report.String()
+}
+
+// tailBuffer keeps the last lines written to it, each cut to its last
+// maxTailLineBytes
+type tailBuffer struct {
+	mutex   sync.Mutex
+	max     int
+	lines   []string
+	partial string
+}
+
+func newTailBuffer(max int) *tailBuffer {
+	return &tailBuffer{max: max}
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	text := b.partial + string(p)
+	lines := strings.Split(text, "\n")
+	b.partial = lineTail(lines[len(lines)-1])
+	for _, line := range lines[:len(lines)-1] {
+		b.lines = append(b.lines, lineTail(line))
+	}
+	if len(b.lines) > b.max {
+		b.lines = b.lines[len(b.lines)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) Lines() []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	lines := append([]string{}, b.lines...)
+	if b.partial != "" {
+		lines = append(lines, b.partial)
+	}
+	if len(lines) > b.max {
+		lines = lines[len(lines)-b.max:]
+	}
+	return lines
+}
+
+// lineTail is the end of the line, at most maxTailLineBytes long and starting
+// at the beginning of a UTF-8 character
+func lineTail(line string) string {
+	if len(line) <= maxTailLineBytes {
+		return line
+	}
+	start := len(line) - maxTailLineBytes
+	for start < len(line) && !utf8.RuneStart(line[start]) {
+		start++
+	}
+	return line[start:]
+}
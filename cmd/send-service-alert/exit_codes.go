@@ -0,0 +1,65 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package main
+
+import (
+	"errors"
+
+	"github.com/pivotal-cf/service-alerts-client/client"
+)
+
+// Exit codes, documented in the README. They must not change, as wrappers
+// such as monit and BOSH errands react to them.
+const (
+	exitFailed           = 1
+	exitHTTPRequestError = 2
+	batchExitSomeFailed  = 3
+	batchExitAllFailed   = 4
+	exitInvalidConfig    = 5
+	exitAuthFailed       = 6
+	exitNotFound         = 7
+	exitRejected         = 8
+	exitTimedOut         = 9
+	exitUnreachable      = 10
+	exitNoRecipients     = 11
+	exitDeliveryFailed   = 12
+)
+
+// exitCode is the exit code for the most specific reason err gives. A timeout
+// after connections kept failing is reported as unreachable.
+func exitCode(err error) int {
+	var (
+		configErr      client.ConfigError
+		authErr        client.AuthError
+		notFoundErr    client.NotFoundError
+		rejectedErr    client.RejectedError
+		unreachableErr client.UnreachableError
+		timeoutErr     client.TimeoutError
+		httpErr        client.HTTPRequestError
+		noRecipients   client.NoRecipientsError
+	)
+	switch {
+	case errors.As(err, &configErr):
+		return exitInvalidConfig
+	case errors.As(err, &authErr):
+		return exitAuthFailed
+	case errors.As(err, &notFoundErr):
+		return exitNotFound
+	case errors.As(err, &rejectedErr):
+		return exitRejected
+	case errors.As(err, &unreachableErr):
+		return exitUnreachable
+	case errors.As(err, &timeoutErr):
+		return exitTimedOut
+	case errors.As(err, &noRecipients):
+		return exitNoRecipients
+	case errors.As(err, &httpErr):
+		return exitHTTPRequestError
+	default:
+		return exitFailed
+	}
+}
@@ -7,49 +7,284 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
 	"github.com/pivotal-cf/service-alerts-client/client"
 
 	"gopkg.in/yaml.v2"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "routes":
+			routes(os.Args[2:])
+			return
+		case "silences":
+			silences(os.Args[2:])
+			return
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "batch":
+			batch(os.Args[2:])
+			return
+		case "exec":
+			execCommand(os.Args[2:])
+			return
+		case "watch":
+			watch(os.Args[2:])
+			return
+		case "doctor":
+			doctor(os.Args[2:])
+			return
+		case "recipients":
+			recipients(os.Args[2:])
+			return
+		case "locales":
+			listLocales(os.Args[2:])
+			return
+		}
+	}
+
 	configFilePath := flag.String("config", "", "config file path")
-	product := flag.String("product", "", "name of product")
-	serviceInstanceID := flag.String("service-instance", "", "service instance ID (optional)")
-	subject := flag.String("subject", "", "email subject")
-	content := flag.String("content", "", "email body content")
+	dryRun := flag.Bool("dry-run", false, "print the notifications instead of sending them")
+	resolveGUIDs := flag.Bool("resolve-guids", false, "look up space GUIDs in Cloud Foundry during a dry run")
+	output := flag.String("output", "text", "text, or json to print the delivery receipts to stdout")
+	trackDelivery := flag.Bool("track-delivery", false, "wait until the notifications are delivered or have failed")
+	logFormat := flag.String("log-format", "text", "text, or json to log requests as lager JSON to stderr")
+	verbose := flag.Bool("verbose", false, "with -log-format json, log every request rather than only failed ones")
+	alert := alertFlags(flag.CommandLine)
 	flag.Parse()
 
-	configBytes, err := ioutil.ReadFile(*configFilePath)
-	mustNot(err)
+	if *output != "text" && *output != "json" {
+		mustNot(fmt.Errorf("-output must be text or json, got '%s'", *output))
+	}
+	mustNot(validateLogFormat(*logFormat))
 
-	var config client.Config
-	must(yaml.Unmarshal(configBytes, &config))
+	config := loadConfig(*configFilePath)
+	if *dryRun {
+		config.DryRun = true
+	}
+	if *resolveGUIDs {
+		config.DryRunResolveGUIDs = true
+	}
+	if *trackDelivery {
+		config.Notifications.DeliveryTracking.Enabled = true
+	}
 
 	logFlags := log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC
 	logger := log.New(os.Stderr, "[service alerts client] ", logFlags)
 
-	alertsClient := client.New(config, logger)
-	clientErr := alertsClient.SendServiceAlert(*product, *subject, *serviceInstanceID, *content)
-	if clientErr != nil {
-		switch clientErr.(type) {
-		case client.HTTPRequestError:
-			logger.Println(clientErr.(client.HTTPRequestError).ErrorMessageForUser())
-			os.Exit(2)
-		default:
-			mustNot(clientErr)
+	alertsClient := withLogFormat(client.New(config, logger), *logFormat, *verbose)
+	receipts, clientErr := alertsClient.SendAlertWithReceipts(limitContent(alert(), config))
+
+	var failedDeliveries int
+	if config.Notifications.DeliveryTracking.Enabled && clientErr == nil {
+		tracker, err := client.NewDeliveryTracker(config.Notifications.DeliveryTracking, alertsClient, clock.NewClock(), logger)
+		mustNot(err)
+		receipts = tracker.WithFailureHandler(func(client.DeliveryFailure) { failedDeliveries++ }).Track(receipts)
+	}
+
+	if *output == "json" {
+		printReceipts(receipts)
+	}
+	if httpErr, ok := clientErr.(client.HTTPRequestError); ok {
+		logger.Println(httpErr.ErrorMessageForUser())
+		os.Exit(exitCode(clientErr))
+	}
+	mustNot(clientErr)
+	if failedDeliveries > 0 {
+		os.Exit(exitDeliveryFailed)
+	}
+}
+
+// printReceipts writes the receipts of the spaces the alert was sent to, which
+// may be none when it was silenced or failed
+func printReceipts(receipts []client.DeliveryReceipt) {
+	if receipts == nil {
+		receipts = []client.DeliveryReceipt{}
+	}
+	output, err := json.MarshalIndent(receipts, "", "  ")
+	mustNot(err)
+	fmt.Println(string(output))
+}
+
+func validateLogFormat(format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("-log-format must be text or json, got '%s'", format)
+	}
+	return nil
+}
+
+// withLogFormat logs the client's requests as lager JSON to stderr when the
+// format is json: only failed requests at info level, and every request at
+// debug level when verbose
+func withLogFormat(alertsClient *client.ServiceAlertsClient, format string, verbose bool) *client.ServiceAlertsClient {
+	if format != "json" {
+		return alertsClient
+	}
+	level := lager.INFO
+	if verbose {
+		level = lager.DEBUG
+	}
+	logger := lager.NewLogger("service-alerts-client")
+	logger.RegisterSink(lager.NewWriterSink(os.Stderr, level))
+	return alertsClient.WithStructuredLogger(logger)
+}
+
+func loadConfig(configFilePath string) client.Config {
+	config, err := readConfig(configFilePath)
+	mustNot(err)
+
+	_, err = client.NewEmailTemplates(config.Templates)
+	mustNot(err)
+	return config
+}
+
+// readConfig reads and parses the config file without validating it
+func readConfig(configFilePath string) (client.Config, error) {
+	configBytes, err := ioutil.ReadFile(configFilePath)
+	if err != nil {
+		return client.Config{}, client.ConfigError{Err: err}
+	}
+
+	var config client.Config
+	if err := yaml.Unmarshal(configBytes, &config); err != nil {
+		return client.Config{}, client.ConfigError{Err: err}
+	}
+	return config, nil
+}
+
+func alertFlags(flags *flag.FlagSet) func() client.Alert {
+	product := flags.String("product", "", "name of product")
+	serviceInstanceID := flags.String("service-instance", "", "service instance ID (optional)")
+	subject := flags.String("subject", "", "email subject")
+	content := flags.String("content", "", "email body content, or - to read it from stdin")
+	contentFile := flags.String("content-file", "", "file to read the email body content from (optional)")
+	severity := flags.String("severity", "critical", "alert severity: info, warning or critical")
+	occurredAt := flags.String("occurred-at", "", "when the alert occurred, in RFC3339 format such as 2016-10-01T12:00:00Z (optional)")
+	labels := keyValueFlag{}
+	flags.Var(labels, "label", "alert label as key=value, may be repeated (optional)")
+	vars := keyValueFlag{}
+	flags.Var(vars, "var", "variable as key=value replacing {{.key}} in the subject and content, may be repeated (optional)")
+
+	return func() client.Alert {
+		parsedSeverity, err := client.ParseSeverity(*severity)
+		mustNot(err)
+
+		alertContent, err := readContent(*content, *contentFile)
+		mustNot(err)
+
+		var alertOccurredAt time.Time
+		if *occurredAt != "" {
+			alertOccurredAt, err = time.Parse(time.RFC3339, *occurredAt)
+			mustNot(err)
 		}
+
+		// content from a file or stdin is often a log excerpt, in which
+		// braces that look like placeholders are left alone
+		contentIsInline := *contentFile == "" && *content != "-"
+
+		alertSubject := *subject
+		if len(vars) > 0 {
+			alertSubject, err = renderVars("subject", alertSubject, vars, true)
+			mustNot(err)
+			alertContent, err = renderVars("content", alertContent, vars, contentIsInline)
+			mustNot(err)
+		}
+
+		return client.Alert{
+			Product:           *product,
+			Subject:           alertSubject,
+			ServiceInstanceID: *serviceInstanceID,
+			Content:           alertContent,
+			Severity:          parsedSeverity,
+			Labels:            labels,
+			OccurredAt:        alertOccurredAt,
+		}
+	}
+}
+
+func readContent(content, contentFile string) (string, error) {
+	if contentFile != "" {
+		if content != "" {
+			return "", errors.New("-content and -content-file cannot be used together")
+		}
+		contents, err := ioutil.ReadFile(contentFile)
+		return string(contents), err
+	}
+	if content == "-" {
+		contents, err := ioutil.ReadAll(os.Stdin)
+		return string(contents), err
+	}
+	return content, nil
+}
+
+var varPlaceholder = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// renderVars replaces {{.name}} placeholders with the -var of that name and
+// leaves any other text alone. Placeholders without a -var are an error when
+// strict, and are kept as they are otherwise.
+func renderVars(name, text string, vars map[string]string, strict bool) (string, error) {
+	var missing []string
+	rendered := varPlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		key := varPlaceholder.FindStringSubmatch(placeholder)[1]
+		value, ok := vars[key]
+		if !ok {
+			missing = append(missing, key)
+			return placeholder
+		}
+		return value
+	})
+	if strict && len(missing) > 0 {
+		return "", fmt.Errorf("%s refers to '%s', which was not given with -var", name, missing[0])
+	}
+	return rendered, nil
+}
+
+func limitContent(alert client.Alert, config client.Config) client.Alert {
+	alert.Content = client.TruncateContent(alert.Content, config.MaxContentBytes)
+	return alert
+}
+
+type keyValueFlag map[string]string
+
+func (f keyValueFlag) String() string {
+	var pairs []string
+	for key, value := range f {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f keyValueFlag) Set(pair string) error {
+	parts := strings.SplitN(pair, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return fmt.Errorf("expected key=value, got '%s'", pair)
 	}
+	f[parts[0]] = parts[1]
+	return nil
 }
 
+// mustNot exits when there is an error, with the exit code for its type
 func mustNot(err error) {
 	if err != nil {
-		log.Fatalln(err)
+		log.Println(err)
+		os.Exit(exitCode(err))
 	}
 }
 
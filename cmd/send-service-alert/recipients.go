@@ -0,0 +1,71 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pivotal-cf/service-alerts-client/client"
+)
+
+func recipients(args []string) {
+	flags := flag.NewFlagSet("recipients", flag.ExitOnError)
+	configFilePath := flags.String("config", "", "config file path")
+	alert := alertFlags(flags)
+	must(flags.Parse(args))
+
+	config := loadConfig(*configFilePath)
+
+	logFlags := log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC
+	logger := log.New(os.Stderr, "[service alerts client] ", logFlags)
+
+	router, err := client.NewRouter(config)
+	mustNot(err)
+	alertsClient := client.New(config, logger)
+
+	empty := false
+	for _, receiver := range router.Route(alert()) {
+		fmt.Printf("%s (%s):\n", receiver.Name, receiver.Destination)
+
+		recipients, err := alertsClient.Recipients(receiver.Destination)
+		mustNot(err)
+		if len(recipients) == 0 {
+			fmt.Println("  WARNING: nobody would receive this alert, the space has no space developers")
+			empty = true
+			continue
+		}
+
+		verified := false
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, recipient := range recipients {
+			email := recipient.Email
+			switch {
+			case email == "":
+				email = "(email unknown)"
+			case !recipient.Verified:
+				email += " (not verified)"
+			default:
+				verified = true
+			}
+			fmt.Fprintf(table, "  %s\t%s\n", recipient.Username, email)
+		}
+		table.Flush()
+
+		if !verified {
+			fmt.Println("  WARNING: nobody may receive this alert, none of the space developers has a verified email")
+			empty = true
+		}
+	}
+
+	if empty {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/pivotal-cf/service-alerts-client/client"
+)
+
+func routes(args []string) {
+	if len(args) == 0 || args[0] != "test" {
+		log.Fatalln("usage: send-service-alert routes test -config <config file path> [alert flags]")
+	}
+
+	flags := flag.NewFlagSet("routes test", flag.ExitOnError)
+	configFilePath := flags.String("config", "", "config file path")
+	alert := alertFlags(flags)
+	must(flags.Parse(args[1:]))
+
+	router, err := client.NewRouter(loadConfig(*configFilePath))
+	mustNot(err)
+
+	for _, receiver := range router.Route(alert()) {
+		fmt.Fprintf(os.Stdout, "%s (%s)\n", receiver.Name, receiver.Destination)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"github.com/pivotal-cf/service-alerts-client/client"
+)
+
+const (
+	// shutdownMargin is added to the send timeout for the default shutdown
+	// timeout, so that alerts being sent can finish, retries included
+	shutdownMargin         = 10 * time.Second
+	warmUpRetryInterval    = 10 * time.Second
+	deferredAlertsInterval = time.Minute
+)
+
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	configFilePath := flags.String("config", "", "config file path")
+	listen := flags.String("listen", "", "address to listen on as host:port or unix:<socket path> (overrides serve.listen)")
+	logFormat := flags.String("log-format", "text", "text, or json to log requests as lager JSON to stderr")
+	verbose := flags.Bool("verbose", false, "with -log-format json, log every request rather than only failed ones")
+	must(flags.Parse(args))
+	mustNot(validateLogFormat(*logFormat))
+
+	config := loadConfig(*configFilePath)
+	if *listen != "" {
+		config.Serve.Listen = *listen
+	}
+
+	logFlags := log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC
+	logger := log.New(os.Stderr, "[service alerts client] ", logFlags)
+	realClock := clock.NewClock()
+
+	metrics := client.NewPrometheusMetrics()
+
+	alertsClient := withLogFormat(client.New(config, logger), *logFormat, *verbose).WithMetrics(metrics)
+	digest, err := client.NewDigestAggregator(config.Digest, alertsClient, realClock, logger)
+	mustNot(err)
+	digest.WithMetrics(metrics)
+	escalator, err := client.NewEscalator(config, alertsClient, realClock, logger)
+	mustNot(err)
+	escalator.WithSilences(alertsClient.Silences())
+	dispatcher, err := client.NewDispatcher(alertsClient)
+	mustNot(err)
+	dispatcher.WithDigest(digest).WithEscalator(escalator)
+
+	server, err := client.NewServer(config.Serve, dispatcher, logger)
+	mustNot(err)
+	server.WithMetrics(metrics)
+	server.Handle("/metrics", metrics)
+
+	alertmanagerMapper, err := client.NewAlertmanagerMapper(config.Alertmanager)
+	mustNot(err)
+	server.Handle("/alertmanager", server.AlertmanagerHandler(alertmanagerMapper))
+
+	if config.Acknowledgement.Secret != "" {
+		var stateStore client.StateStore = client.NewMemoryStateStore()
+		if config.StateFile != "" {
+			stateStore = client.NewFileStateStore(config.StateFile)
+		}
+		escalator.WithStateStore(stateStore)
+		signer := client.NewAckSigner(config.Acknowledgement, realClock)
+		server.HandlePublic("/acknowledge", client.NewAcknowledgementHandler(signer, stateStore, realClock, logger))
+	}
+
+	var heartbeats *client.HeartbeatMonitor
+	if config.Heartbeats.File != "" {
+		heartbeats, err = client.NewHeartbeatMonitor(config.Heartbeats, dispatcher, realClock, logger)
+		mustNot(err)
+		heartbeatsHandler := client.NewHeartbeatsHandler(heartbeats)
+		server.Handle("/heartbeats", heartbeatsHandler)
+		server.Handle("/heartbeats/", heartbeatsHandler)
+	}
+
+	listener, err := listenerFor(config.Serve)
+	mustNot(err)
+	httpServer := &http.Server{Handler: server}
+
+	stop := make(chan struct{})
+	digestDone := make(chan struct{})
+	go func() {
+		digest.Run(stop)
+		close(digestDone)
+	}()
+	go warmUp(alertsClient, server, logger, stop)
+	go sendDeferredAlerts(dispatcher, logger, stop)
+	if heartbeats != nil {
+		go heartbeats.Run(stop)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Printf("Listening on %s", config.Serve.Listen)
+		serveErr <- httpServer.Serve(listener)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	select {
+	case err := <-serveErr:
+		mustNot(err)
+	case sig := <-signals:
+		logger.Printf("Received %s, draining in-flight alerts", sig)
+	}
+
+	server.SetReady(false)
+	shutdownTimeout := config.SendTimeout() + shutdownMargin
+	if config.Serve.ShutdownTimeoutSeconds != 0 {
+		shutdownTimeout = time.Duration(config.Serve.ShutdownTimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		logger.Printf("Failed to drain in-flight alerts: %s", err)
+	}
+
+	close(stop)
+	<-digestDone
+	escalator.Stop()
+	logger.Println("Stopped")
+}
+
+func listenerFor(config client.Serve) (net.Listener, error) {
+	if config.Listen == "" {
+		return nil, client.ConfigError{Err: errors.New("serve.listen is not set in the config")}
+	}
+
+	if strings.HasPrefix(config.Listen, "unix:") {
+		socketPath := strings.TrimPrefix(config.Listen, "unix:")
+		if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.Listen("unix", socketPath)
+	}
+
+	listener, err := net.Listen("tcp", config.Listen)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.TLS.CertFile == "" {
+		if config.TLS.ClientCAFile != "" {
+			listener.Close()
+			return nil, errors.New("serve.tls.client_ca_file requires serve.tls.cert_file and serve.tls.key_file")
+		}
+		return listener, nil
+	}
+
+	tlsConfig, err := serverTLSConfig(config.TLS)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return tls.NewListener(listener, tlsConfig), nil
+}
+
+func serverTLSConfig(config client.ServeTLS) (*tls.Config, error) {
+	certificate, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+
+	if config.ClientCAFile != "" {
+		caBytes, err := ioutil.ReadFile(config.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caBytes) {
+			return nil, errors.New("no certificates found in serve.tls.client_ca_file")
+		}
+		tlsConfig.ClientCAs = clientCAs
+		// Public endpoints such as health checks and acknowledgement links are
+		// reachable without a certificate, the API checks for one itself
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+func warmUp(alertsClient *client.ServiceAlertsClient, server *client.Server, logger *log.Logger, stop <-chan struct{}) {
+	for {
+		err := alertsClient.WarmUp()
+		if err == nil {
+			server.SetReady(true)
+			return
+		}
+		logger.Printf("Not ready, retrying in %s: %s", warmUpRetryInterval, err)
+
+		select {
+		case <-time.After(warmUpRetryInterval):
+		case <-stop:
+			return
+		}
+	}
+}
+
+func sendDeferredAlerts(dispatcher *client.Dispatcher, logger *log.Logger, stop <-chan struct{}) {
+	ticker := time.NewTicker(deferredAlertsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := dispatcher.SendDeferred(); err != nil {
+				logger.Printf("Failed to send deferred alerts: %s", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
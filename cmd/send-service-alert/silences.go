@@ -0,0 +1,149 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pivotal-cf/service-alerts-client/client"
+)
+
+const silenceTimeLayout = "2006-01-02 15:04"
+
+const silencesUsage = "usage: send-service-alert silences create|list|expire|flush -config <config file path> [flags]"
+
+func silences(args []string) {
+	if len(args) == 0 {
+		log.Fatalln(silencesUsage)
+	}
+
+	flags := flag.NewFlagSet("silences "+args[0], flag.ExitOnError)
+	configFilePath := flags.String("config", "", "config file path")
+
+	switch args[0] {
+	case "create":
+		createSilence(flags, configFilePath, args[1:])
+	case "list":
+		must(flags.Parse(args[1:]))
+		listSilences(silenceStore(*configFilePath))
+	case "expire":
+		must(flags.Parse(args[1:]))
+		if flags.NArg() != 1 {
+			log.Fatalln("usage: send-service-alert silences expire -config <config file path> <silence ID>")
+		}
+		mustNot(silenceStore(*configFilePath).Expire(flags.Arg(0), time.Now()))
+	case "flush":
+		must(flags.Parse(args[1:]))
+		logFlags := log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC
+		logger := log.New(os.Stderr, "[service alerts client] ", logFlags)
+		mustNot(client.New(loadConfig(*configFilePath), logger).SendDeferredAlerts())
+	default:
+		log.Fatalln(silencesUsage)
+	}
+}
+
+func createSilence(flags *flag.FlagSet, configFilePath *string, args []string) {
+	product := flags.String("product", "", "only silence alerts for this product (optional)")
+	serviceInstance := flags.String("service-instance", "", "only silence alerts for this service instance (optional)")
+	labels := keyValueFlag{}
+	flags.Var(labels, "label", "only silence alerts with this label as key=value, may be repeated (optional)")
+	start := flags.String("start", "", "start of the window as '"+silenceTimeLayout+"' (default now)")
+	duration := flags.Duration("duration", time.Hour, "length of the window")
+	timezone := flags.String("timezone", "UTC", "timezone of the start time and of recurring windows")
+	recurrence := flags.String("recurrence", "", "repeat the window 'daily' or 'weekly' (optional)")
+	action := flags.String("action", client.SilenceActionDrop, "what to do with matching alerts: 'drop' or 'defer'")
+	comment := flags.String("comment", "", "reason for the silence (optional)")
+	must(flags.Parse(args))
+
+	location, err := time.LoadLocation(*timezone)
+	mustNot(err)
+
+	startsAt := time.Now().In(location)
+	if *start != "" {
+		startsAt, err = time.ParseInLocation(silenceTimeLayout, *start, location)
+		mustNot(err)
+	}
+
+	silence, err := silenceStore(*configFilePath).Create(client.Silence{
+		Comment:         *comment,
+		Product:         *product,
+		ServiceInstance: *serviceInstance,
+		Labels:          labels,
+		Action:          *action,
+		StartsAt:        startsAt,
+		EndsAt:          startsAt.Add(*duration),
+		Timezone:        *timezone,
+		Recurrence:      *recurrence,
+	})
+	mustNot(err)
+
+	fmt.Println(silence.ID)
+}
+
+func listSilences(store *client.SilenceStore) {
+	silences, err := store.List()
+	mustNot(err)
+
+	now := time.Now()
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tSTATE\tACTION\tSTARTS\tENDS\tRECURRENCE\tMATCHERS\tCOMMENT")
+	for _, silence := range silences {
+		location, _ := time.LoadLocation(silence.Timezone)
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			silence.ID,
+			silenceState(silence, now),
+			silence.Action,
+			silence.StartsAt.In(location).Format(time.RFC3339),
+			silence.EndsAt.In(location).Format(time.RFC3339),
+			silence.Recurrence,
+			silenceMatchers(silence),
+			silence.Comment,
+		)
+	}
+	mustNot(writer.Flush())
+}
+
+func silenceState(silence client.Silence, now time.Time) string {
+	switch {
+	case silence.Expired(now):
+		return "expired"
+	case silence.ActiveAt(now):
+		return "active"
+	case silence.Recurrence == "" && !now.Before(silence.EndsAt):
+		return "ended"
+	default:
+		return "pending"
+	}
+}
+
+func silenceMatchers(silence client.Silence) string {
+	matchers := keyValueFlag{}
+	for key, value := range silence.Labels {
+		matchers[key] = value
+	}
+	if silence.Product != "" {
+		matchers["product"] = silence.Product
+	}
+	if silence.ServiceInstance != "" {
+		matchers["service_instance"] = silence.ServiceInstance
+	}
+	return matchers.String()
+}
+
+func silenceStore(configFilePath string) *client.SilenceStore {
+	config := loadConfig(configFilePath)
+	if config.SilencesFile == "" {
+		mustNot(client.ConfigError{Err: errors.New("silences_file is not set in the config")})
+	}
+	return client.NewSilenceStore(config.SilencesFile)
+}
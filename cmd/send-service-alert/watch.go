@@ -0,0 +1,49 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"code.cloudfoundry.org/clock"
+	"github.com/pivotal-cf/service-alerts-client/client"
+)
+
+func watch(args []string) {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	configFilePath := flags.String("config", "", "config file path")
+	must(flags.Parse(args))
+
+	config := loadConfig(*configFilePath)
+
+	logFlags := log.Ldate | log.Ltime | log.Lmicroseconds | log.LUTC
+	logger := log.New(os.Stderr, "[service alerts client] ", logFlags)
+
+	dispatcher, err := client.NewDispatcher(client.New(config, logger))
+	mustNot(err)
+	watcher, err := client.NewLogWatcher(config.Watch, dispatcher, clock.NewClock(), logger)
+	mustNot(err)
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		watcher.Run(stop)
+		close(done)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-signals
+	logger.Printf("Received %s, stopping", sig)
+
+	close(stop)
+	<-done
+}
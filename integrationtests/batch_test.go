@@ -0,0 +1,191 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("send-service-alert batch", func() {
+	var (
+		dir                string
+		configFilePath     string
+		cfServer           *ghttp.Server
+		uaaServer          *ghttp.Server
+		notificationServer *ghttp.Server
+		input              string
+		session            *gexec.Session
+	)
+
+	type result struct {
+		Line   int    `json:"line"`
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+
+	results := func() []result {
+		var parsed []result
+		for _, line := range strings.Split(strings.TrimSpace(string(session.Out.Contents())), "\n") {
+			var r result
+			Expect(json.Unmarshal([]byte(line), &r)).To(Succeed())
+			parsed = append(parsed, r)
+		}
+		sort.Slice(parsed, func(i, j int) bool { return parsed[i].Line < parsed[j].Line })
+		return parsed
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "service-alerts-batch-tests")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfServer = ghttp.NewServer()
+		uaaServer = ghttp.NewServer()
+		notificationServer = ghttp.NewServer()
+		fakeCloudFoundry(cfServer, uaaServer, notificationServer)
+
+		configFilePath = filepath.Join(dir, "config.yml")
+		config := fmt.Sprintf(`
+cloud_controller:
+  url: %s
+notifications:
+  service_url: %s
+  cf_org: test-org
+  cf_space: some-cf-space
+timeout_seconds: 1
+max_content_bytes: 60
+silences_file: %s
+`, cfServer.URL(), notificationServer.URL(), filepath.Join(dir, "silences.json"))
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+	})
+
+	JustBeforeEach(func() {
+		cmd := exec.Command(sendServiceAlertsBin, "batch", "-config", configFilePath, "-concurrency", "2")
+		cmd.Stdin = strings.NewReader(input)
+		var err error
+		session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 5).Should(gexec.Exit())
+	})
+
+	AfterEach(func() {
+		cfServer.Close()
+		uaaServer.Close()
+		notificationServer.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Context("when every alert is sent", func() {
+		BeforeEach(func() {
+			input = `{"product": "redis", "subject": "one"}
+
+{"product": "mysql", "subject": "two", "severity": "warning"}
+`
+		})
+
+		It("prints a result per alert and exits with 0", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(results()).To(Equal([]result{
+				{Line: 1, Status: "sent"},
+				{Line: 3, Status: "sent"},
+			}))
+			Expect(notificationServer.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Context("when an alert's content is too long", func() {
+		var notifications chan map[string]string
+
+		BeforeEach(func() {
+			input = fmt.Sprintf(`{"product": "redis", "subject": "one", "content": "%s"}`, strings.Repeat("x", 200))
+
+			notifications = make(chan map[string]string, 1)
+			notificationServer.RouteToHandler("POST", "/spaces/3e6ca4d8-738f-46cb-989b-14290b887b47", func(w http.ResponseWriter, req *http.Request) {
+				notification := map[string]string{}
+				Expect(json.NewDecoder(req.Body).Decode(&notification)).To(Succeed())
+				notifications <- notification
+				w.Write([]byte("[]"))
+			})
+		})
+
+		It("truncates the content", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+
+			var notification map[string]string
+			Eventually(notifications).Should(Receive(&notification))
+			Expect(notification["text"]).To(ContainSubstring("bytes omitted"))
+			Expect(notification["text"]).NotTo(ContainSubstring(strings.Repeat("x", 200)))
+		})
+	})
+
+	Context("when an alert is silenced", func() {
+		BeforeEach(func() {
+			create := exec.Command(sendServiceAlertsBin, "silences", "create", "-config", configFilePath, "-product", "redis")
+			created, err := gexec.Start(create, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(created, 5).Should(gexec.Exit(0))
+
+			input = `{"product": "redis", "subject": "one"}
+{"product": "mysql", "subject": "two"}
+`
+		})
+
+		It("reports it as silenced and exits with 0", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(results()).To(Equal([]result{
+				{Line: 1, Status: "silenced"},
+				{Line: 2, Status: "sent"},
+			}))
+			Expect(notificationServer.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("when some alerts are invalid", func() {
+		BeforeEach(func() {
+			input = `{"product": "redis", "subject": "one"}
+{"product":
+{"subject": "no product"}
+`
+		})
+
+		It("sends the valid alerts and exits with 3", func() {
+			Expect(session.ExitCode()).To(Equal(3))
+
+			parsed := results()
+			Expect(parsed).To(HaveLen(3))
+			Expect(parsed[0].Status).To(Equal("sent"))
+			Expect(parsed[1].Status).To(Equal("invalid"))
+			Expect(parsed[1].Error).To(ContainSubstring("alert not parseable"))
+			Expect(parsed[2]).To(Equal(result{Line: 3, Status: "invalid", Error: "product is required"}))
+		})
+	})
+
+	Context("when every alert fails", func() {
+		BeforeEach(func() {
+			input = `{"product": "redis", "subject": "one"}`
+			notificationServer.Close()
+		})
+
+		It("exits with 4", func() {
+			Expect(session.ExitCode()).To(Equal(4))
+			Expect(results()[0].Status).To(Equal("failed"))
+		})
+	})
+})
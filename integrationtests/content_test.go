@@ -0,0 +1,182 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("send-service-alert content", func() {
+	var (
+		dir                string
+		configFilePath     string
+		cfServer           *ghttp.Server
+		uaaServer          *ghttp.Server
+		notificationServer *ghttp.Server
+		notificationMutex  sync.Mutex
+		notification       map[string]string
+		args               []string
+		stdin              string
+		session            *gexec.Session
+	)
+
+	sentNotification := func() map[string]string {
+		notificationMutex.Lock()
+		defer notificationMutex.Unlock()
+		return notification
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "service-alerts-content-tests")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfServer = ghttp.NewServer()
+		uaaServer = ghttp.NewServer()
+		notificationServer = ghttp.NewServer()
+		fakeCloudFoundry(cfServer, uaaServer, notificationServer)
+		notificationServer.RouteToHandler("POST", "/spaces/3e6ca4d8-738f-46cb-989b-14290b887b47", func(w http.ResponseWriter, req *http.Request) {
+			received := map[string]string{}
+			Expect(json.NewDecoder(req.Body).Decode(&received)).To(Succeed())
+			notificationMutex.Lock()
+			notification = received
+			notificationMutex.Unlock()
+			w.Write([]byte("[]"))
+		})
+
+		configFilePath = filepath.Join(dir, "config.yml")
+		config := fmt.Sprintf(`
+cloud_controller:
+  url: %s
+notifications:
+  service_url: %s
+  cf_org: test-org
+  cf_space: some-cf-space
+timeout_seconds: 1
+max_content_bytes: 60
+`, cfServer.URL(), notificationServer.URL())
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+
+		stdin = ""
+		notificationMutex.Lock()
+		notification = nil
+		notificationMutex.Unlock()
+	})
+
+	JustBeforeEach(func() {
+		cmd := exec.Command(sendServiceAlertsBin, append([]string{"-config", configFilePath, "-product", "redis"}, args...)...)
+		cmd.Stdin = strings.NewReader(stdin)
+		var err error
+		session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 5).Should(gexec.Exit())
+	})
+
+	AfterEach(func() {
+		cfServer.Close()
+		uaaServer.Close()
+		notificationServer.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Context("when the content is read from a file", func() {
+		BeforeEach(func() {
+			contentFile := filepath.Join(dir, "content.txt")
+			Expect(ioutil.WriteFile(contentFile, []byte("line one\nline two"), 0600)).To(Succeed())
+			args = []string{"-subject", "from a file", "-content-file", contentFile}
+		})
+
+		It("sends the file contents", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(sentNotification()["text"]).To(ContainSubstring("line one\nline two"))
+		})
+	})
+
+	Context("when the content is read from stdin", func() {
+		BeforeEach(func() {
+			stdin = "from stdin"
+			args = []string{"-subject", "from stdin", "-content", "-"}
+		})
+
+		It("sends the piped content", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(sentNotification()["text"]).To(ContainSubstring("from stdin"))
+		})
+	})
+
+	Context("when template variables are given", func() {
+		BeforeEach(func() {
+			args = []string{"-subject", "{{.job}} failed", "-content", "on {{.host}}", "-var", "job=backup", "-var", "host=vm-1"}
+		})
+
+		It("renders them into the subject and content", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(sentNotification()["subject"]).To(Equal("[Service Alert][redis] backup failed"))
+			Expect(sentNotification()["text"]).To(ContainSubstring("on vm-1"))
+		})
+	})
+
+	Context("when template variables are given for content read from stdin", func() {
+		BeforeEach(func() {
+			stdin = "{{ if }} on {{.host}} {{.Other}}"
+			args = []string{"-subject", "{{.job}} failed", "-content", "-", "-var", "job=backup", "-var", "host=vm-1"}
+		})
+
+		It("replaces only the given variables and leaves other braces alone", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(sentNotification()["subject"]).To(Equal("[Service Alert][redis] backup failed"))
+			Expect(sentNotification()["text"]).To(ContainSubstring("{{ if }} on vm-1 {{.Other}}"))
+		})
+	})
+
+	Context("when a template variable is missing", func() {
+		BeforeEach(func() {
+			args = []string{"-subject", "{{.job}} failed", "-var", "host=vm-1"}
+		})
+
+		It("fails without sending", func() {
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(sentNotification()).To(BeNil())
+		})
+	})
+
+	Context("when the content exceeds max_content_bytes", func() {
+		BeforeEach(func() {
+			args = []string{"-subject", "too long", "-content", strings.Repeat("x", 40) + strings.Repeat("y", 40)}
+		})
+
+		It("keeps the start and end of the content around a marker", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(sentNotification()["text"]).To(ContainSubstring(strings.Repeat("x", 16) + "\n\n[... 56 of 80 bytes omitted ...]\n\n" + strings.Repeat("y", 8) + "\n"))
+		})
+	})
+
+	Context("when both -content and -content-file are given", func() {
+		BeforeEach(func() {
+			args = []string{"-content", "inline", "-content-file", "content.txt"}
+		})
+
+		It("fails", func() {
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Err).To(gbytes.Say("-content and -content-file cannot be used together"))
+		})
+	})
+})
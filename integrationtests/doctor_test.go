@@ -0,0 +1,215 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("send-service-alert doctor", func() {
+	var (
+		dir                string
+		configFilePath     string
+		cfServer           *ghttp.Server
+		uaaServer          *ghttp.Server
+		notificationServer *ghttp.Server
+		clientScope        string
+		clientStatus       int
+		session            *gexec.Session
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "service-alerts-doctor-tests")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfServer = ghttp.NewServer()
+		uaaServer = ghttp.NewServer()
+		notificationServer = ghttp.NewServer()
+		fakeCloudFoundry(cfServer, uaaServer, notificationServer)
+		notificationServer.RouteToHandler("GET", "/info", ghttp.RespondWith(http.StatusOK, `{"version": 1}`))
+
+		clientScope = "notifications.write"
+		clientStatus = http.StatusOK
+
+		configFilePath = filepath.Join(dir, "config.yml")
+		config := fmt.Sprintf(`
+cloud_controller:
+  url: %s
+  user: some-cf-user
+  password: some-cf-password
+notifications:
+  service_url: %s
+  cf_org: test-org
+  cf_space: some-cf-space
+  client_id: some-client
+  client_secret: some-secret
+`, cfServer.URL(), notificationServer.URL())
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+	})
+
+	JustBeforeEach(func() {
+		// the handler is only set up once the nested BeforeEach blocks have
+		// picked the scope and status, as it runs on the server's goroutines
+		scope, status := clientScope, clientStatus
+		uaaServer.RouteToHandler("POST", "/oauth/token", func(w http.ResponseWriter, req *http.Request) {
+			Expect(req.ParseForm()).To(Succeed())
+			if req.Form.Get("grant_type") == "password" {
+				ghttp.RespondWithJSONEncoded(http.StatusOK, map[string]interface{}{
+					"access_token": "cf-token",
+					"scope":        "openid cloud_controller.read",
+				})(w, req)
+				return
+			}
+			ghttp.RespondWithJSONEncoded(status, map[string]interface{}{
+				"access_token": "notifications-token",
+				"scope":        scope,
+			})(w, req)
+		})
+
+		var err error
+		session, err = gexec.Start(exec.Command(sendServiceAlertsBin, "doctor", "-config", configFilePath, "-timeout", "1"), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 5).Should(gexec.Exit())
+	})
+
+	AfterEach(func() {
+		cfServer.Close()
+		uaaServer.Close()
+		notificationServer.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Context("when everything is set up correctly", func() {
+		It("passes every check without sending a notification", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] config`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] locales`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] Cloud Controller reachable`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] Cloud Controller /v2/info: UAA at %s`, regexp.QuoteMeta(uaaServer.URL())))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] UAA password grant: user some-cf-user`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] CF user token scopes: openid cloud_controller.read`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] UAA client credentials grant: client some-client`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] notifications client token scopes: notifications.write`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] org test-org`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] space some-cf-space in org test-org`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] notifications service reachable`))
+
+			for _, req := range notificationServer.ReceivedRequests() {
+				Expect(req.Method).To(Equal("GET"))
+			}
+		})
+	})
+
+	Context("when the notifications client is missing its scope", func() {
+		BeforeEach(func() {
+			clientScope = "uaa.none"
+		})
+
+		It("fails the scope check with a hint", func() {
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Out).To(gbytes.Say(`\[FAIL\] notifications client token scopes: token has none of the scopes notifications.write`))
+			Expect(session.Out).To(gbytes.Say(`hint: give the UAA client the authority notifications.write`))
+		})
+	})
+
+	Context("when the notifications client credentials are wrong", func() {
+		BeforeEach(func() {
+			clientStatus = http.StatusUnauthorized
+		})
+
+		It("fails the grant and skips the checks depending on it", func() {
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Out).To(gbytes.Say(`\[FAIL\] UAA client credentials grant: UAA expected to return HTTP 200, got 401`))
+			Expect(session.Out).To(gbytes.Say(`hint: check notifications.client_id and notifications.client_secret`))
+			Expect(session.Out).To(gbytes.Say(`\[SKIP\] notifications client token scopes: UAA client credentials grant did not pass`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] org test-org`))
+		})
+	})
+
+	Context("when a locale has no message catalog", func() {
+		BeforeEach(func() {
+			config, err := ioutil.ReadFile(configFilePath)
+			Expect(err).NotTo(HaveOccurred())
+			config = append(config, "  locale: xx\n"...)
+			Expect(ioutil.WriteFile(configFilePath, config, 0600)).To(Succeed())
+		})
+
+		It("fails only the locales check", func() {
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] config`))
+			Expect(session.Out).To(gbytes.Say(`\[FAIL\] locales: no message catalog for notifications.locale xx, English would be used`))
+			Expect(session.Out).To(gbytes.Say(`hint: use one of the locales listed by send-service-alert locales`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] Cloud Controller reachable`))
+			Expect(session.Out).To(gbytes.Say(`\[PASS\] notifications service reachable`))
+		})
+	})
+
+	Context("when the config file cannot be read", func() {
+		BeforeEach(func() {
+			Expect(os.Remove(configFilePath)).To(Succeed())
+		})
+
+		It("fails the config check with a hint", func() {
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Out).To(gbytes.Say(`\[FAIL\] config: invalid config: open %s: no such file or directory`, regexp.QuoteMeta(configFilePath)))
+			Expect(session.Out).To(gbytes.Say(`hint: check the -config path and that the file can be read`))
+		})
+	})
+
+	Context("when the config file is not valid YAML", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(configFilePath, []byte("notifications: [\n"), 0600)).To(Succeed())
+		})
+
+		It("fails the config check with a hint", func() {
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Out).To(gbytes.Say(`\[FAIL\] config: invalid config: yaml: `))
+			Expect(session.Out).To(gbytes.Say(`hint: fix the config file, see the README for its format`))
+		})
+	})
+
+	Context("when a template is invalid", func() {
+		BeforeEach(func() {
+			config, err := ioutil.ReadFile(configFilePath)
+			Expect(err).NotTo(HaveOccurred())
+			config = append(config, "templates:\n  subject: \"{{.Subject\"\n"...)
+			Expect(ioutil.WriteFile(configFilePath, config, 0600)).To(Succeed())
+		})
+
+		It("fails the config check with a hint and skips the checks depending on it", func() {
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Out).To(gbytes.Say(`\[FAIL\] config: invalid templates config: `))
+			Expect(session.Out).To(gbytes.Say(`hint: fix the config file, see the README for its format`))
+			Expect(session.Out).To(gbytes.Say(`\[SKIP\] Cloud Controller reachable: config did not pass`))
+		})
+	})
+
+	Context("when config is missing", func() {
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(configFilePath, []byte("notifications: {cf_org: test-org}\n"), 0600)).To(Succeed())
+		})
+
+		It("reports the missing fields and skips everything else", func() {
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Out).To(gbytes.Say(`\[FAIL\] config: not set: cloud_controller.url, cloud_controller.user`))
+			Expect(session.Out).To(gbytes.Say(`\[SKIP\] Cloud Controller reachable: config did not pass`))
+		})
+	})
+})
@@ -0,0 +1,100 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pivotal-cf/service-alerts-client/client"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("send-service-alert -dry-run", func() {
+	var (
+		dir                string
+		configFilePath     string
+		cfServer           *ghttp.Server
+		uaaServer          *ghttp.Server
+		notificationServer *ghttp.Server
+		args               []string
+		session            *gexec.Session
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "service-alerts-dry-run-tests")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfServer = ghttp.NewServer()
+		uaaServer = ghttp.NewServer()
+		notificationServer = ghttp.NewServer()
+		fakeCloudFoundry(cfServer, uaaServer, notificationServer)
+
+		configFilePath = filepath.Join(dir, "config.yml")
+		config := fmt.Sprintf(`
+cloud_controller:
+  url: %s
+notifications:
+  service_url: %s
+  cf_org: test-org
+  cf_space: some-cf-space
+timeout_seconds: 1
+`, cfServer.URL(), notificationServer.URL())
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+	})
+
+	JustBeforeEach(func() {
+		cmd := exec.Command(sendServiceAlertsBin, append([]string{"-config", configFilePath, "-product", "redis", "-subject", "down", "-dry-run"}, args...)...)
+		var err error
+		session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 5).Should(gexec.Exit(0))
+	})
+
+	AfterEach(func() {
+		cfServer.Close()
+		uaaServer.Close()
+		notificationServer.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("prints the notification without contacting Cloud Foundry", func() {
+		var notification client.DryRunNotification
+		Expect(json.Unmarshal(session.Out.Contents(), &notification)).To(Succeed())
+		Expect(notification.Destination).To(Equal(client.Destination{CFOrg: "test-org", CFSpace: "some-cf-space"}))
+		Expect(notification.Request.Subject).To(Equal("[Service Alert][redis] down"))
+		Expect(notification.URL).To(BeEmpty())
+
+		Expect(cfServer.ReceivedRequests()).To(BeEmpty())
+		Expect(notificationServer.ReceivedRequests()).To(BeEmpty())
+	})
+
+	Context("with -resolve-guids", func() {
+		BeforeEach(func() {
+			args = []string{"-resolve-guids"}
+		})
+
+		It("looks up the space but does not send the notification", func() {
+			var notification client.DryRunNotification
+			Expect(json.Unmarshal(session.Out.Contents(), &notification)).To(Succeed())
+			Expect(notification.SpaceGUID).To(Equal("3e6ca4d8-738f-46cb-989b-14290b887b47"))
+			Expect(notification.URL).To(Equal(notificationServer.URL() + "/spaces/3e6ca4d8-738f-46cb-989b-14290b887b47"))
+
+			Expect(cfServer.ReceivedRequests()).NotTo(BeEmpty())
+			Expect(notificationServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+})
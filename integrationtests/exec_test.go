@@ -0,0 +1,178 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("send-service-alert exec", func() {
+	var (
+		dir                string
+		configFilePath     string
+		cfServer           *ghttp.Server
+		uaaServer          *ghttp.Server
+		notificationServer *ghttp.Server
+		notifications      []map[string]string
+	)
+
+	run := func(args ...string) *gexec.Session {
+		cmd := exec.Command(sendServiceAlertsBin, append([]string{"exec", "-config", configFilePath, "-product", "backups"}, args...)...)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 5).Should(gexec.Exit())
+		return session
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "service-alerts-exec-tests")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfServer = ghttp.NewServer()
+		uaaServer = ghttp.NewServer()
+		notificationServer = ghttp.NewServer()
+		fakeCloudFoundry(cfServer, uaaServer, notificationServer)
+		notifications = nil
+		notificationServer.RouteToHandler("POST", "/spaces/3e6ca4d8-738f-46cb-989b-14290b887b47", func(w http.ResponseWriter, req *http.Request) {
+			notification := map[string]string{}
+			Expect(json.NewDecoder(req.Body).Decode(&notification)).To(Succeed())
+			notifications = append(notifications, notification)
+			w.Write([]byte("[]"))
+		})
+
+		configFilePath = filepath.Join(dir, "config.yml")
+		config := fmt.Sprintf(`
+cloud_controller:
+  url: %s
+notifications:
+  service_url: %s
+  cf_org: test-org
+  cf_space: some-cf-space
+timeout_seconds: 1
+state_file: %s
+`, cfServer.URL(), notificationServer.URL(), filepath.Join(dir, "state.json"))
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		cfServer.Close()
+		uaaServer.Close()
+		notificationServer.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Context("when the command succeeds", func() {
+		It("passes its output through and sends nothing", func() {
+			session := run("--", "sh", "-c", "echo all good")
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(session.Out).To(gbytes.Say("all good"))
+			Expect(notifications).To(BeEmpty())
+		})
+	})
+
+	Context("when the command fails", func() {
+		It("sends an alert with the tail of its output and passes the exit code through", func() {
+			session := run("-tail-lines", "2", "--", "sh", "-c", "echo one; echo two; sleep 0.1; echo three >&2; exit 3")
+			Expect(session.ExitCode()).To(Equal(3))
+			Expect(notifications).To(HaveLen(1))
+			Expect(notifications[0]["subject"]).To(Equal("[Service Alert][backups] sh -c echo one; echo two; sleep 0.1; echo three >&2; exit 3 failed"))
+			Expect(notifications[0]["text"]).To(ContainSubstring("Status: exited with 3"))
+			Expect(notifications[0]["text"]).To(ContainSubstring("Last 2 lines of output:\ntwo\nthree\n"))
+		})
+	})
+
+	Context("when the output has very long lines", func() {
+		It("keeps only the end of each line", func() {
+			session := run("-tail-lines", "1", "--", "sh", "-c", "head -c 100000 /dev/zero | tr '\\0' x; printf end; exit 1")
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(notifications).To(HaveLen(1))
+			Expect(notifications[0]["text"]).To(ContainSubstring("Last 1 lines of output:\n" + strings.Repeat("x", 4093) + "end\n"))
+		})
+	})
+
+	Context("when an alert flag is invalid", func() {
+		It("fails without running the command", func() {
+			marker := filepath.Join(dir, "ran")
+			session := run("-severity", "urgent", "--", "touch", marker)
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Err).To(gbytes.Say("urgent"))
+			Expect(marker).NotTo(BeAnExistingFile())
+			Expect(notifications).To(BeEmpty())
+		})
+	})
+
+	Context("when -tail-lines is negative", func() {
+		It("fails without running the command", func() {
+			session := run("-tail-lines", "-1", "--", "true")
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Err).To(gbytes.Say("-tail-lines must not be negative, got -1"))
+		})
+	})
+
+	Context("when the command times out", func() {
+		It("kills it, sends an alert and exits with 124", func() {
+			session := run("-timeout", "100ms", "-subject", "backup hung", "--", "sh", "-c", "sleep 10")
+			Expect(session.ExitCode()).To(Equal(124))
+			Expect(notifications).To(HaveLen(1))
+			Expect(notifications[0]["text"]).To(ContainSubstring("Status: timed out after 100ms"))
+		})
+	})
+
+	Context("when the command is killed by a signal", func() {
+		It("exits with 128 plus the signal number", func() {
+			session := run("--", "sh", "-c", "kill -TERM $$")
+			Expect(session.ExitCode()).To(Equal(143))
+			Expect(notifications[0]["text"]).To(ContainSubstring("Status: killed by signal terminated"))
+		})
+	})
+
+	Context("with -resolve-on-success", func() {
+		It("resolves the alert the next time the command succeeds", func() {
+			Expect(run("-resolve-on-success", "-subject", "backup failed", "--", "false").ExitCode()).To(Equal(1))
+			Expect(run("-resolve-on-success", "-subject", "backup failed", "--", "true").ExitCode()).To(Equal(0))
+			Expect(run("-resolve-on-success", "-subject", "backup failed", "--", "true").ExitCode()).To(Equal(0))
+
+			Expect(notifications).To(HaveLen(2))
+			Expect(notifications[0]["subject"]).To(Equal("[Service Alert][backups] backup failed"))
+			Expect(notifications[1]["subject"]).To(Equal("[Service Alert][backups][Resolved] backup failed"))
+		})
+
+		It("keeps the alert firing when the resolution cannot be sent", func() {
+			Expect(run("-resolve-on-success", "-subject", "backup failed", "--", "false").ExitCode()).To(Equal(1))
+
+			notificationServer.RouteToHandler("POST", "/spaces/3e6ca4d8-738f-46cb-989b-14290b887b47", ghttp.RespondWith(http.StatusInternalServerError, ""))
+			session := run("-resolve-on-success", "-subject", "backup failed", "--", "true")
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(session.Err).To(gbytes.Say("Failed to send resolved alert"))
+
+			notificationServer.RouteToHandler("POST", "/spaces/3e6ca4d8-738f-46cb-989b-14290b887b47", func(w http.ResponseWriter, req *http.Request) {
+				notification := map[string]string{}
+				Expect(json.NewDecoder(req.Body).Decode(&notification)).To(Succeed())
+				notifications = append(notifications, notification)
+				w.Write([]byte("[]"))
+			})
+			Expect(run("-resolve-on-success", "-subject", "backup failed", "--", "true").ExitCode()).To(Equal(0))
+
+			Expect(notifications).To(HaveLen(2))
+			Expect(notifications[1]["subject"]).To(Equal("[Service Alert][backups][Resolved] backup failed"))
+		})
+	})
+})
@@ -0,0 +1,124 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("send-service-alert logging", func() {
+	var (
+		dir                string
+		configFilePath     string
+		cfServer           *ghttp.Server
+		uaaServer          *ghttp.Server
+		notificationServer *ghttp.Server
+		args               []string
+		session            *gexec.Session
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "service-alerts-logging-tests")
+		Expect(err).NotTo(HaveOccurred())
+		configFilePath = filepath.Join(dir, "config.yml")
+
+		cfServer = ghttp.NewServer()
+		uaaServer = ghttp.NewServer()
+		notificationServer = ghttp.NewServer()
+		fakeCloudFoundry(cfServer, uaaServer, notificationServer)
+		notificationServer.RouteToHandler("POST", "/spaces/3e6ca4d8-738f-46cb-989b-14290b887b47",
+			ghttp.RespondWith(http.StatusInternalServerError, `{"error":"bad token","access_token":"leaked-token"}`))
+
+		args = nil
+	})
+
+	JustBeforeEach(func() {
+		config := fmt.Sprintf(`
+cloud_controller:
+  url: %s
+  password: some-cf-password
+notifications:
+  service_url: %s
+  cf_org: test-org
+  cf_space: some-cf-space
+  client_secret: some-client-secret
+timeout_seconds: 1
+`, cfServer.URL(), notificationServer.URL())
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+
+		cmd := exec.Command(sendServiceAlertsBin, append([]string{"-config", configFilePath, "-product", "redis", "-subject", "down"}, args...)...)
+		var err error
+		session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 5).Should(gexec.Exit())
+	})
+
+	AfterEach(func() {
+		cfServer.Close()
+		uaaServer.Close()
+		notificationServer.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("redacts tokens from response bodies in the log", func() {
+		Expect(session.ExitCode()).To(Equal(9))
+		Expect(session.Err).To(gbytes.Say(`Retrying in 1 seconds, CF Notifications request error: .*"access_token":"\[REDACTED\]"`))
+		Expect(string(session.Err.Contents())).NotTo(ContainSubstring("leaked-token"))
+	})
+
+	Context("when the log format is json", func() {
+		BeforeEach(func() {
+			args = []string{"-log-format", "json"}
+		})
+
+		It("logs failed requests as lager JSON without credentials", func() {
+			Expect(session.ExitCode()).To(Equal(9))
+			Expect(session.Err).To(gbytes.Say(`"message":"service-alerts-client.request-failed"`))
+			Expect(session.Err).NotTo(gbytes.Say("Retrying in"))
+
+			log := string(session.Err.Contents())
+			Expect(log).To(ContainSubstring(`"label":"CF Notifications"`))
+			Expect(log).To(ContainSubstring(`"status":500`))
+			Expect(log).To(ContainSubstring(`\"access_token\":\"[REDACTED]\"`))
+			Expect(log).NotTo(ContainSubstring("leaked-token"))
+			Expect(log).NotTo(ContainSubstring("some-client-secret"))
+			Expect(log).NotTo(ContainSubstring("some-cf-password"))
+			Expect(log).NotTo(ContainSubstring(`"message":"service-alerts-client.request"`))
+		})
+
+		Context("and -verbose is given", func() {
+			BeforeEach(func() {
+				args = append(args, "-verbose")
+			})
+
+			It("logs every request at debug level", func() {
+				Expect(session.ExitCode()).To(Equal(9))
+				Expect(session.Err).To(gbytes.Say(`"message":"service-alerts-client.request","log_level":0`))
+				Expect(string(session.Err.Contents())).To(ContainSubstring(`"label":"UAA"`))
+			})
+		})
+	})
+
+	It("rejects unknown log formats", func() {
+		session, err := gexec.Start(exec.Command(sendServiceAlertsBin, "-config", configFilePath, "-log-format", "xml"), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 5).Should(gexec.Exit(1))
+		Expect(session.Err).To(gbytes.Say("-log-format must be text or json, got 'xml'"))
+	})
+})
@@ -0,0 +1,139 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pivotal-cf/service-alerts-client/client"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("send-service-alert delivery receipts", func() {
+	var (
+		dir                string
+		configFilePath     string
+		cfServer           *ghttp.Server
+		uaaServer          *ghttp.Server
+		notificationServer *ghttp.Server
+		zeroRecipients     string
+		response           string
+		args               []string
+		session            *gexec.Session
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "service-alerts-receipts-tests")
+		Expect(err).NotTo(HaveOccurred())
+		configFilePath = filepath.Join(dir, "config.yml")
+
+		cfServer = ghttp.NewServer()
+		uaaServer = ghttp.NewServer()
+		notificationServer = ghttp.NewServer()
+		fakeCloudFoundry(cfServer, uaaServer, notificationServer)
+		notificationServer.RouteToHandler("POST", "/spaces/3e6ca4d8-738f-46cb-989b-14290b887b47", func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(response))
+		})
+
+		zeroRecipients = ""
+		args = nil
+		response = `[{"email":"dev@example.com","notification_id":"notification-1","recipient":"user-1","status":"queued","vcap_request_id":"request-1"}]`
+	})
+
+	JustBeforeEach(func() {
+		config := fmt.Sprintf(`
+cloud_controller:
+  url: %s
+notifications:
+  service_url: %s
+  cf_org: test-org
+  cf_space: some-cf-space
+  zero_recipients: %s
+  delivery_tracking:
+    poll_interval_seconds: 1
+    timeout_seconds: 5
+timeout_seconds: 1
+`, cfServer.URL(), notificationServer.URL(), zeroRecipients)
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+
+		cmd := exec.Command(sendServiceAlertsBin, append([]string{"-config", configFilePath, "-product", "redis", "-subject", "down", "-output", "json"}, args...)...)
+		var err error
+		session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 5).Should(gexec.Exit())
+	})
+
+	AfterEach(func() {
+		cfServer.Close()
+		uaaServer.Close()
+		notificationServer.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("prints the recipients reported by the notifications service", func() {
+		Expect(session.ExitCode()).To(Equal(0))
+
+		var receipts []client.DeliveryReceipt
+		Expect(json.Unmarshal(session.Out.Contents(), &receipts)).To(Succeed())
+		Expect(receipts).To(Equal([]client.DeliveryReceipt{{
+			Destination: client.Destination{CFOrg: "test-org", CFSpace: "some-cf-space"},
+			Recipients: []client.RecipientReceipt{
+				{Recipient: "user-1", Email: "dev@example.com", NotificationID: "notification-1", Status: "queued"},
+			},
+		}}))
+	})
+
+	Context("when tracking delivery", func() {
+		BeforeEach(func() {
+			args = []string{"-track-delivery"}
+			notificationServer.RouteToHandler("GET", "/messages/notification-1", ghttp.RespondWith(http.StatusOK, `{"status":"failed"}`))
+		})
+
+		It("reports failed deliveries and exits with 12", func() {
+			Expect(session.ExitCode()).To(Equal(12))
+			Expect(session.Err).To(gbytes.Say("Delivery failed: notification notification-1 to dev@example.com in org: test-org, space: some-cf-space has status failed"))
+
+			var receipts []client.DeliveryReceipt
+			Expect(json.Unmarshal(session.Out.Contents(), &receipts)).To(Succeed())
+			Expect(receipts[0].Recipients[0].Status).To(Equal("failed"))
+		})
+	})
+
+	Context("when there are no recipients", func() {
+		BeforeEach(func() {
+			response = "[]"
+		})
+
+		It("warns by default", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(session.Err).To(gbytes.Say("notification to org: test-org, space: some-cf-space has no recipients"))
+		})
+
+		Context("and zero_recipients is error", func() {
+			BeforeEach(func() {
+				zeroRecipients = "error"
+			})
+
+			It("fails with exit code 11", func() {
+				Expect(session.ExitCode()).To(Equal(11))
+				Expect(session.Err).To(gbytes.Say("notification to org: test-org, space: some-cf-space has no recipients"))
+			})
+		})
+	})
+})
@@ -0,0 +1,117 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("send-service-alert recipients", func() {
+	const developersPath = "/v2/spaces/3e6ca4d8-738f-46cb-989b-14290b887b47/developers"
+
+	var (
+		dir                string
+		configFilePath     string
+		cfServer           *ghttp.Server
+		uaaServer          *ghttp.Server
+		notificationServer *ghttp.Server
+		session            *gexec.Session
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "service-alerts-recipients-tests")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfServer = ghttp.NewServer()
+		uaaServer = ghttp.NewServer()
+		notificationServer = ghttp.NewServer()
+		fakeCloudFoundry(cfServer, uaaServer, notificationServer)
+
+		configFilePath = filepath.Join(dir, "config.yml")
+		config := fmt.Sprintf(`
+cloud_controller:
+  url: %s
+notifications:
+  service_url: %s
+  cf_org: test-org
+  cf_space: some-cf-space
+timeout_seconds: 1
+`, cfServer.URL(), notificationServer.URL())
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		session, err = gexec.Start(exec.Command(sendServiceAlertsBin, "recipients", "-config", configFilePath), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session, 5).Should(gexec.Exit())
+	})
+
+	AfterEach(func() {
+		cfServer.Close()
+		uaaServer.Close()
+		notificationServer.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Context("when the space has developers", func() {
+		BeforeEach(func() {
+			cfServer.RouteToHandler("GET", developersPath, func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Query().Get("page") == "2" {
+					w.Write([]byte(`{"next_url": null, "resources": [{"metadata": {"guid": "user-2"}, "entity": {"username": "bob"}}]}`))
+					return
+				}
+				w.Write([]byte(`{"next_url": "` + developersPath + `?page=2", "resources": [{"metadata": {"guid": "user-1"}, "entity": {"username": "alice"}}]}`))
+			})
+			uaaServer.RouteToHandler("GET", "/Users/user-1", ghttp.RespondWith(http.StatusOK, `{"id": "user-1", "emails": [{"value": "alice@example.com", "primary": true}], "verified": true}`))
+			uaaServer.RouteToHandler("GET", "/Users/user-2", ghttp.RespondWith(http.StatusForbidden, `{}`))
+		})
+
+		It("lists them with their emails", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(session.Out).To(gbytes.Say(`default \(org: test-org, space: some-cf-space\):`))
+			Expect(session.Out).To(gbytes.Say(`alice\s+alice@example.com\n`))
+			Expect(session.Out).To(gbytes.Say(`bob\s+\(email unknown\)`))
+		})
+	})
+
+	Context("when none of the developers has a verified email", func() {
+		BeforeEach(func() {
+			cfServer.RouteToHandler("GET", developersPath, ghttp.RespondWith(http.StatusOK, `{"resources": [{"metadata": {"guid": "user-1"}, "entity": {"username": "alice"}}]}`))
+			uaaServer.RouteToHandler("GET", "/Users/user-1", ghttp.RespondWith(http.StatusOK, `{"id": "user-1", "emails": [{"value": "alice@example.com", "primary": true}], "verified": false}`))
+		})
+
+		It("lists them, warns and exits with 1", func() {
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Out).To(gbytes.Say(`alice\s+alice@example.com \(not verified\)`))
+			Expect(session.Out).To(gbytes.Say("WARNING: nobody may receive this alert, none of the space developers has a verified email"))
+		})
+	})
+
+	Context("when the space has no developers", func() {
+		BeforeEach(func() {
+			cfServer.RouteToHandler("GET", developersPath, ghttp.RespondWith(http.StatusOK, `{"resources": []}`))
+		})
+
+		It("warns and exits with 1", func() {
+			Expect(session.ExitCode()).To(Equal(1))
+			Expect(session.Out).To(gbytes.Say("WARNING: nobody would receive this alert"))
+		})
+	})
+})
@@ -0,0 +1,84 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("send-service-alert routes test", func() {
+	var (
+		configFilePath string
+		args           []string
+		session        *gexec.Session
+	)
+
+	BeforeEach(func() {
+		configFile, err := ioutil.TempFile("", "service-alerts-routes-tests")
+		Expect(err).NotTo(HaveOccurred())
+		defer configFile.Close()
+		configFilePath = configFile.Name()
+
+		_, err = configFile.WriteString(`
+notifications:
+  cf_org: test-org
+  cf_space: test-space
+receivers:
+- name: on-call
+  cf_org: test-org
+  cf_space: on-call
+route:
+  routes:
+  - match:
+      product: redis
+      severity: critical
+    receivers: [on-call]
+`)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.Remove(configFilePath)).To(Succeed())
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		cmd := exec.Command(sendServiceAlertsBin, append([]string{"routes", "test", "-config", configFilePath}, args...)...)
+		session, err = gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit())
+	})
+
+	Context("when the alert matches a route", func() {
+		BeforeEach(func() {
+			args = []string{"-product", "redis", "-severity", "critical"}
+		})
+
+		It("prints the matching receiver", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(session.Out).To(gbytes.Say(`on-call \(org: test-org, space: on-call\)`))
+		})
+	})
+
+	Context("when the alert matches no route", func() {
+		BeforeEach(func() {
+			args = []string{"-product", "redis", "-severity", "warning"}
+		})
+
+		It("prints the default receiver", func() {
+			Expect(session.ExitCode()).To(Equal(0))
+			Expect(session.Out).To(gbytes.Say(`default \(org: test-org, space: test-space\)`))
+		})
+	})
+})
@@ -277,8 +277,8 @@ var _ = Describe("send-service-alert executable", func() {
 					skipSSLValidation = makeBool(false)
 				})
 
-				It("exits with 2", func() {
-					Expect(runningBin.ExitCode()).To(Equal(2))
+				It("exits with 10", func() {
+					Expect(runningBin.ExitCode()).To(Equal(10))
 				})
 			})
 
@@ -287,8 +287,8 @@ var _ = Describe("send-service-alert executable", func() {
 					skipSSLValidation = nil
 				})
 
-				It("exits with 2", func() {
-					Expect(runningBin.ExitCode()).To(Equal(2))
+				It("exits with 10", func() {
+					Expect(runningBin.ExitCode()).To(Equal(10))
 				})
 			})
 		})
@@ -333,7 +333,7 @@ var _ = Describe("send-service-alert executable", func() {
 				Expect(requestMap).To(HaveKeyWithValue("subject", "[Service Alert]["+product+"] "+subject))
 				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring(fmt.Sprintf("Alert from %s, service instance %s:", product, serviceInstanceID))))
 				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring(content)))
-				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring("[Alert generated at ")))
+				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring("[Alert sent at ")))
 				Expect(requestMap).To(HaveKeyWithValue("reply_to", replyTo))
 			})
 		})
@@ -365,7 +365,7 @@ var _ = Describe("send-service-alert executable", func() {
 				Expect(requestMap).To(HaveKeyWithValue("subject", "[Service Alert]["+product+"] "+subject))
 				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring(fmt.Sprintf("Alert from %s, service instance %s:", product, serviceInstanceID))))
 				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring(content)))
-				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring("[Alert generated at ")))
+				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring("[Alert sent at ")))
 				Expect(requestMap).NotTo(HaveKey("reply_to"))
 			})
 		})
@@ -397,7 +397,7 @@ var _ = Describe("send-service-alert executable", func() {
 				Expect(requestMap).To(HaveKeyWithValue("subject", "[Service Alert]["+product+"] "+subject))
 				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring(fmt.Sprintf("Alert from %s:", product))))
 				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring(content)))
-				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring("[Alert generated at ")))
+				Expect(requestMap).To(HaveKeyWithValue("text", ContainSubstring("[Alert sent at ")))
 				Expect(requestMap).To(HaveKeyWithValue("reply_to", replyTo))
 			})
 		})
@@ -420,8 +420,8 @@ var _ = Describe("send-service-alert executable", func() {
 					By("Logging a user error message to stderr")
 					Expect(stderr).To(gbytes.Say(fmt.Sprintf("failed to send notification to org: %s, space: %s", cfOrgName, cfSpaceName)))
 
-					By("exiting with code 2")
-					Expect(runningBin.ExitCode()).To(Equal(2))
+					By("exiting with code 9")
+					Expect(runningBin.ExitCode()).To(Equal(9))
 				})
 			})
 
@@ -453,8 +453,8 @@ var _ = Describe("send-service-alert executable", func() {
 					By("Logging a user error message to stderr")
 					Expect(stderr).To(gbytes.Say(fmt.Sprintf("failed to send notification to org: %s, space: %s", cfOrgName, cfSpaceName)))
 
-					By("exiting with code 2")
-					Expect(runningBin.ExitCode()).To(Equal(2))
+					By("exiting with code 9")
+					Expect(runningBin.ExitCode()).To(Equal(9))
 				})
 			})
 
@@ -474,8 +474,8 @@ var _ = Describe("send-service-alert executable", func() {
 					By("Logging a user error message to stderr")
 					Expect(stderr).To(gbytes.Say(fmt.Sprintf("failed to send notification to org: %s, space: %s", cfOrgName, cfSpaceName)))
 
-					By("exiting with code 2")
-					Expect(runningBin.ExitCode()).To(Equal(2))
+					By("exiting with code 9")
+					Expect(runningBin.ExitCode()).To(Equal(9))
 				})
 			})
 
@@ -502,8 +502,8 @@ var _ = Describe("send-service-alert executable", func() {
 					By("Logging a user error message to stderr")
 					Expect(stderr).To(gbytes.Say(fmt.Sprintf("failed to send notification to org: %s, space: %s", cfOrgName, cfSpaceName)))
 
-					By("exiting with code 2")
-					Expect(runningBin.ExitCode()).To(Equal(2))
+					By("exiting with code 9")
+					Expect(runningBin.ExitCode()).To(Equal(9))
 				})
 			})
 
@@ -517,8 +517,8 @@ var _ = Describe("send-service-alert executable", func() {
 					)
 				})
 
-				It("exits with 1", func() {
-					Expect(runningBin.ExitCode()).To(Equal(1))
+				It("exits with 8", func() {
+					Expect(runningBin.ExitCode()).To(Equal(8))
 				})
 
 				It("does not retry the request", func() {
@@ -544,8 +544,8 @@ var _ = Describe("send-service-alert executable", func() {
 					By("Logging a user error message to stderr")
 					Expect(stderr).To(gbytes.Say(fmt.Sprintf("failed to send notification to org: %s, space: %s", cfOrgName, cfSpaceName)))
 
-					By("exiting with code 2")
-					Expect(runningBin.ExitCode()).To(Equal(2))
+					By("exiting with code 10")
+					Expect(runningBin.ExitCode()).To(Equal(10))
 				})
 			})
 
@@ -559,8 +559,8 @@ var _ = Describe("send-service-alert executable", func() {
 					)
 				})
 
-				It("exits with 1", func() {
-					Expect(runningBin.ExitCode()).To(Equal(1))
+				It("exits with 8", func() {
+					Expect(runningBin.ExitCode()).To(Equal(8))
 				})
 
 				It("does not retry the request", func() {
@@ -593,8 +593,8 @@ var _ = Describe("send-service-alert executable", func() {
 					By("Logging a user error message to stderr")
 					Expect(stderr).To(gbytes.Say(fmt.Sprintf("failed to send notification to org: %s, space: %s", cfOrgName, cfSpaceName)))
 
-					By("exiting with code 2")
-					Expect(runningBin.ExitCode()).To(Equal(2))
+					By("exiting with code 9")
+					Expect(runningBin.ExitCode()).To(Equal(9))
 				})
 			})
 		})
@@ -621,8 +621,8 @@ var _ = Describe("send-service-alert executable", func() {
 				By("Logging a user error message to stderr")
 				Expect(stderr).To(gbytes.Say(fmt.Sprintf("failed to send notification to org: %s, space: %s", cfOrgName, cfSpaceName)))
 
-				By("exiting with code 2")
-				Expect(runningBin.ExitCode()).To(Equal(2))
+				By("exiting with code 9")
+				Expect(runningBin.ExitCode()).To(Equal(9))
 			})
 		})
 
@@ -657,8 +657,8 @@ var _ = Describe("send-service-alert executable", func() {
 				By("Logging a user error message to stderr")
 				Expect(stderr).To(gbytes.Say(fmt.Sprintf("failed to send notification to org: %s, space: %s", cfOrgName, cfSpaceName)))
 
-				By("exiting with code 2")
-				Expect(runningBin.ExitCode()).To(Equal(2))
+				By("exiting with code 10")
+				Expect(runningBin.ExitCode()).To(Equal(10))
 			})
 		})
 
@@ -671,8 +671,8 @@ var _ = Describe("send-service-alert executable", func() {
 					))
 				})
 
-				It("exits with 1", func() {
-					Expect(runningBin.ExitCode()).To(Equal(1))
+				It("exits with 6", func() {
+					Expect(runningBin.ExitCode()).To(Equal(6))
 				})
 
 				It("does not retry the request", func() {
@@ -700,8 +700,8 @@ var _ = Describe("send-service-alert executable", func() {
 					)
 				})
 
-				It("exits with 1", func() {
-					Expect(runningBin.ExitCode()).To(Equal(1))
+				It("exits with 6", func() {
+					Expect(runningBin.ExitCode()).To(Equal(6))
 				})
 
 				It("logs the error", func() {
@@ -730,8 +730,8 @@ var _ = Describe("send-service-alert executable", func() {
 				By("Logging a user error message to stderr")
 				Expect(stderr).To(gbytes.Say(fmt.Sprintf("failed to send notification to org: %s, space: %s", cfOrgName, cfSpaceName)))
 
-				By("exiting with code 2")
-				Expect(runningBin.ExitCode()).To(Equal(2))
+				By("exiting with code 10")
+				Expect(runningBin.ExitCode()).To(Equal(10))
 			})
 		})
 
@@ -744,8 +744,8 @@ var _ = Describe("send-service-alert executable", func() {
 				)
 			})
 
-			It("exits with 1", func() {
-				Expect(runningBin.ExitCode()).To(Equal(1))
+			It("exits with 6", func() {
+				Expect(runningBin.ExitCode()).To(Equal(6))
 			})
 
 			It("does not retry the request", func() {
@@ -776,8 +776,8 @@ var _ = Describe("send-service-alert executable", func() {
 				By("Logging a user error message to stderr")
 				Expect(stderr).To(gbytes.Say(fmt.Sprintf("failed to send notification to org: %s, space: %s", cfOrgName, cfSpaceName)))
 
-				By("exiting with code 2")
-				Expect(runningBin.ExitCode()).To(Equal(2))
+				By("exiting with code 9")
+				Expect(runningBin.ExitCode()).To(Equal(9))
 			})
 		})
 
@@ -808,8 +808,8 @@ var _ = Describe("send-service-alert executable", func() {
 				)
 			})
 
-			It("exits with 1", func() {
-				Expect(runningBin.ExitCode()).To(Equal(1))
+			It("exits with 7", func() {
+				Expect(runningBin.ExitCode()).To(Equal(7))
 			})
 
 			It("logs the error", func() {
@@ -843,8 +843,8 @@ var _ = Describe("send-service-alert executable", func() {
 				)
 			})
 
-			It("exits with 1", func() {
-				Expect(runningBin.ExitCode()).To(Equal(1))
+			It("exits with 7", func() {
+				Expect(runningBin.ExitCode()).To(Equal(7))
 			})
 
 			It("logs the error", func() {
@@ -0,0 +1,266 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("send-service-alert serve", func() {
+	var (
+		dir                string
+		cfServer           *ghttp.Server
+		uaaServer          *ghttp.Server
+		notificationServer *ghttp.Server
+		session            *gexec.Session
+		address            string
+		baseURL            string
+		httpClient         *http.Client
+		extraConfig        string
+		notificationMutex  sync.Mutex
+		notificationBodies [][]byte
+	)
+
+	post := func(path, token, body string) *http.Response {
+		req, err := http.NewRequest("POST", baseURL+path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := httpClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		return resp
+	}
+
+	notificationBody := func(i int) []byte {
+		notificationMutex.Lock()
+		defer notificationMutex.Unlock()
+		return notificationBodies[i]
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "service-alerts-serve-tests")
+		Expect(err).NotTo(HaveOccurred())
+
+		address = freeAddress()
+		baseURL = "http://" + address
+		httpClient = http.DefaultClient
+		extraConfig = ""
+
+		cfServer = ghttp.NewServer()
+		uaaServer = ghttp.NewServer()
+		notificationServer = ghttp.NewServer()
+		fakeCloudFoundry(cfServer, uaaServer, notificationServer)
+
+		notificationMutex.Lock()
+		notificationBodies = nil
+		notificationMutex.Unlock()
+		notificationServer.RouteToHandler("POST", "/spaces/3e6ca4d8-738f-46cb-989b-14290b887b47", func(w http.ResponseWriter, req *http.Request) {
+			body, err := ioutil.ReadAll(req.Body)
+			Expect(err).NotTo(HaveOccurred())
+			notificationMutex.Lock()
+			notificationBodies = append(notificationBodies, body)
+			notificationMutex.Unlock()
+			w.Write([]byte("[]"))
+		})
+	})
+
+	JustBeforeEach(func() {
+		configFilePath := filepath.Join(dir, "config.yml")
+		config := fmt.Sprintf(`
+cloud_controller:
+  url: %s
+  user: some-cf-user
+  password: some-cf-password
+notifications:
+  service_url: %s
+  cf_org: test-org
+  cf_space: some-cf-space
+  client_id: some-client
+  client_secret: some-secret
+timeout_seconds: 1
+serve:
+  listen: %s
+  bearer_tokens: [some-token]
+%s`, cfServer.URL(), notificationServer.URL(), address, extraConfig)
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+
+		var err error
+		session, err = gexec.Start(exec.Command(sendServiceAlertsBin, "serve", "-config", configFilePath), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+
+		Eventually(func() int {
+			resp, err := httpClient.Get(baseURL + "/ready")
+			if err != nil {
+				return 0
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}, 5).Should(Equal(http.StatusOK))
+	})
+
+	AfterEach(func() {
+		session.Kill().Wait()
+		cfServer.Close()
+		uaaServer.Close()
+		notificationServer.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("sends alerts with warm tokens", func() {
+		Expect(uaaServer.ReceivedRequests()).To(HaveLen(2))
+
+		for i := 0; i < 2; i++ {
+			resp := post("/alerts", "some-token", `{"product": "redis", "subject": "down", "content": "redis is down"}`)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(ioutil.ReadAll(resp.Body)).To(ContainSubstring(`"status":"sent"`))
+		}
+
+		Expect(notificationServer.ReceivedRequests()).To(HaveLen(2))
+		Expect(uaaServer.ReceivedRequests()).To(HaveLen(2))
+	})
+
+	It("sends a group of Alertmanager alerts as one notification", func() {
+		resp := post("/alertmanager", "some-token", `{
+			"status": "resolved",
+			"groupLabels": {"alertname": "RedisDown"},
+			"commonLabels": {"alertname": "RedisDown", "product": "redis"},
+			"alerts": [
+				{"status": "resolved", "labels": {"instance": "10.0.0.1"}, "annotations": {"summary": "Redis down on 10.0.0.1"}},
+				{"status": "resolved", "labels": {"instance": "10.0.0.2"}, "annotations": {"summary": "Redis down on 10.0.0.2"}}
+			]
+		}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		Expect(notificationServer.ReceivedRequests()).To(HaveLen(1))
+		var notification map[string]string
+		Expect(json.Unmarshal(notificationBody(0), &notification)).To(Succeed())
+		Expect(notification["subject"]).To(Equal("[Service Alert][redis][Resolved] RedisDown"))
+		Expect(notification["text"]).To(ContainSubstring("[resolved] Redis down on 10.0.0.1"))
+		Expect(notification["text"]).To(ContainSubstring("[resolved] Redis down on 10.0.0.2"))
+	})
+
+	It("rejects callers without a bearer token", func() {
+		resp := post("/alerts", "", `{"product": "redis"}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+		Expect(notificationServer.ReceivedRequests()).To(BeEmpty())
+	})
+
+	It("serves metrics for Prometheus to callers with a bearer token", func() {
+		resp := post("/alerts", "some-token", `{"product": "redis", "subject": "down"}`)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		req, err := http.NewRequest("GET", baseURL+"/metrics", nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Authorization", "Bearer some-token")
+		resp, err = httpClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+
+		metrics, err := ioutil.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(metrics)).To(ContainSubstring(`service_alerts_sends_total{backend="cf_notifications",outcome="no_recipients"} 1`))
+		Expect(string(metrics)).To(ContainSubstring(`service_alerts_send_duration_seconds_count{backend="cf_notifications",outcome="no_recipients"} 1`))
+		Expect(string(metrics)).To(ContainSubstring(`service_alerts_queue_depth{queue="in_flight"} 0`))
+
+		resp, err = httpClient.Get(baseURL + "/metrics")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("shuts down gracefully on SIGTERM", func() {
+		session.Signal(syscall.SIGTERM)
+		Eventually(session, 5).Should(gexec.Exit(0))
+	})
+
+	Context("when heartbeats are configured", func() {
+		BeforeEach(func() {
+			extraConfig = fmt.Sprintf(`
+heartbeats:
+  file: %s
+  check_interval_seconds: 1
+`, filepath.Join(dir, "heartbeats.json"))
+		})
+
+		It("alerts when a heartbeat is missed and resolves it on the next ping", func() {
+			resp := post("/heartbeats/nightly-backup?interval=1s&product=mysql", "some-token", "")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+			Eventually(notificationServer.ReceivedRequests, 5).Should(HaveLen(1))
+			var notification map[string]string
+			Expect(json.Unmarshal(notificationBody(0), &notification)).To(Succeed())
+			Expect(notification["subject"]).To(Equal("[Service Alert][mysql] Heartbeat nightly-backup missed"))
+
+			resp = post("/heartbeats/nightly-backup", "some-token", "")
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(notificationServer.ReceivedRequests()).To(HaveLen(2))
+			Expect(json.Unmarshal(notificationBody(1), &notification)).To(Succeed())
+			Expect(notification["subject"]).To(Equal("[Service Alert][mysql][Resolved] Heartbeat nightly-backup missed"))
+		})
+	})
+
+	Context("when listening on a unix socket", func() {
+		BeforeEach(func() {
+			socketPath := filepath.Join(dir, "alerts.sock")
+			address = "unix:" + socketPath
+			baseURL = "http://unix"
+			httpClient = &http.Client{Transport: &http.Transport{
+				Dial: func(_, _ string) (net.Conn, error) { return net.Dial("unix", socketPath) },
+			}}
+		})
+
+		It("accepts alerts on the socket", func() {
+			resp := post("/alerts", "some-token", `{"product": "redis"}`)
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+})
+
+func freeAddress() string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).NotTo(HaveOccurred())
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// fakeCloudFoundry answers every request the client makes to send alerts to
+// test-org/some-cf-space, however many alerts are sent
+func fakeCloudFoundry(cfServer, uaaServer, notificationServer *ghttp.Server) {
+	cfServer.RouteToHandler("GET", "/v2/info", ghttp.RespondWithJSONEncoded(http.StatusOK, map[string]interface{}{
+		"token_endpoint": uaaServer.URL(),
+	}))
+	cfServer.RouteToHandler("GET", "/v2/organizations", ghttp.RespondWith(http.StatusOK, mustReadFile("fixtures/cf_orgs_response.json")))
+	cfServer.RouteToHandler("GET", "/v2/organizations/97160533-c474-41dc-8068-4354171361d9/spaces", ghttp.RespondWith(http.StatusOK, mustReadFile("fixtures/cf_org_spaces_response.json")))
+	uaaServer.RouteToHandler("POST", "/oauth/token", ghttp.RespondWithJSONEncoded(http.StatusOK, map[string]interface{}{
+		"access_token": "some-token",
+		"expires_in":   43199,
+	}))
+	notificationServer.RouteToHandler("POST", "/spaces/3e6ca4d8-738f-46cb-989b-14290b887b47", ghttp.RespondWith(http.StatusOK, "[]"))
+}
+
+func mustReadFile(path string) []byte {
+	contents, err := ioutil.ReadFile(path)
+	Expect(err).NotTo(HaveOccurred())
+	return contents
+}
@@ -0,0 +1,162 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("send-service-alert silences", func() {
+	var (
+		dir            string
+		configFilePath string
+		cfServer       *ghttp.Server
+	)
+
+	run := func(args ...string) *gexec.Session {
+		session, err := gexec.Start(exec.Command(sendServiceAlertsBin, args...), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(session).Should(gexec.Exit())
+		return session
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "service-alerts-silences-tests")
+		Expect(err).NotTo(HaveOccurred())
+
+		cfServer = ghttp.NewServer()
+		configFilePath = filepath.Join(dir, "config.yml")
+		config := fmt.Sprintf(`
+cloud_controller:
+  url: %s
+notifications:
+  cf_org: test-org
+  cf_space: test-space
+timeout_seconds: 1
+silences_file: %s
+`, cfServer.URL(), filepath.Join(dir, "silences.json"))
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		cfServer.Close()
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	It("creates, lists and expires silences", func() {
+		created := run("silences", "create", "-config", configFilePath, "-product", "redis", "-duration", "2h", "-comment", "upgrade")
+		Expect(created.ExitCode()).To(Equal(0))
+		silenceID := strings.TrimSpace(string(created.Out.Contents()))
+		Expect(silenceID).NotTo(BeEmpty())
+
+		listed := run("silences", "list", "-config", configFilePath)
+		Expect(listed.ExitCode()).To(Equal(0))
+		Expect(listed.Out).To(gbytes.Say(`%s\s+active\s+drop`, regexp.QuoteMeta(silenceID)))
+		Expect(listed.Out).To(gbytes.Say(`product=redis\s+upgrade`))
+
+		Expect(run("silences", "expire", "-config", configFilePath, silenceID).ExitCode()).To(Equal(0))
+
+		listed = run("silences", "list", "-config", configFilePath)
+		Expect(listed.Out).To(gbytes.Say(`%s\s+expired`, regexp.QuoteMeta(silenceID)))
+	})
+
+	It("drops alerts matching an active silence without contacting Cloud Foundry", func() {
+		Expect(run("silences", "create", "-config", configFilePath, "-product", "redis").ExitCode()).To(Equal(0))
+
+		sent := run("-config", configFilePath, "-product", "redis", "-subject", "down", "-content", "redis is down")
+		Expect(sent.ExitCode()).To(Equal(0))
+		Expect(sent.Err).To(gbytes.Say("Dropping alert for redis, silenced by"))
+		Expect(cfServer.ReceivedRequests()).To(BeEmpty())
+	})
+
+	Context("when a deferred alert fails for one of its receivers", func() {
+		var (
+			uaaServer          *ghttp.Server
+			notificationServer *ghttp.Server
+		)
+
+		BeforeEach(func() {
+			uaaServer = ghttp.NewServer()
+			notificationServer = ghttp.NewServer()
+			fakeCloudFoundry(cfServer, uaaServer, notificationServer)
+			cfServer.RouteToHandler("GET", "/v2/organizations/97160533-c474-41dc-8068-4354171361d9/spaces", func(w http.ResponseWriter, req *http.Request) {
+				if strings.Contains(req.URL.Query().Get("q"), "missing-space") {
+					w.Write([]byte(`{"total_results": 0, "resources": []}`))
+					return
+				}
+				w.Write(mustReadFile("fixtures/cf_org_spaces_response.json"))
+			})
+
+			config := fmt.Sprintf(`
+cloud_controller:
+  url: %s
+  user: some-cf-user
+  password: some-cf-password
+notifications:
+  service_url: %s
+  cf_org: test-org
+  cf_space: test-space
+  client_id: some-client
+  client_secret: some-secret
+receivers:
+- name: missing
+  cf_org: test-org
+  cf_space: missing-space
+route:
+  receivers: [default, missing]
+timeout_seconds: 1
+silences_file: %s
+`, cfServer.URL(), notificationServer.URL(), filepath.Join(dir, "silences.json"))
+			Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			uaaServer.Close()
+			notificationServer.Close()
+		})
+
+		It("defers it again for only that receiver", func() {
+			created := run("silences", "create", "-config", configFilePath, "-product", "redis", "-action", "defer")
+			Expect(created.ExitCode()).To(Equal(0))
+			Expect(run("-config", configFilePath, "-product", "redis", "-subject", "down").ExitCode()).To(Equal(0))
+			Expect(run("silences", "expire", "-config", configFilePath, strings.TrimSpace(string(created.Out.Contents()))).ExitCode()).To(Equal(0))
+
+			flushed := run("silences", "flush", "-config", configFilePath)
+			Expect(flushed.ExitCode()).NotTo(Equal(0))
+			Expect(flushed.Err).To(gbytes.Say("receiver missing: "))
+			Expect(notificationServer.ReceivedRequests()).To(HaveLen(1))
+			silencesFile, err := ioutil.ReadFile(filepath.Join(dir, "silences.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(silencesFile)).To(MatchRegexp(`"receivers": \[\s*"missing"\s*\]`))
+
+			Expect(run("silences", "flush", "-config", configFilePath).ExitCode()).NotTo(Equal(0))
+			Expect(notificationServer.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	It("fails when the config has no silences file", func() {
+		Expect(ioutil.WriteFile(configFilePath, []byte("notifications: {}"), 0600)).To(Succeed())
+
+		listed := run("silences", "list", "-config", configFilePath)
+		Expect(listed.ExitCode()).To(Equal(5))
+		Expect(listed.Err).To(gbytes.Say("silences_file is not set in the config"))
+	})
+})
@@ -0,0 +1,105 @@
+// Copyright (C) 2016-Present Pivotal Software, Inc. All rights reserved.
+// This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License"); you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+// Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+
+package integration_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+
+	"github.com/pivotal-cf/service-alerts-client/client"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("send-service-alert with email templates", func() {
+	var (
+		dir            string
+		configFilePath string
+		bodyFilePath   string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "service-alerts-templates-tests")
+		Expect(err).NotTo(HaveOccurred())
+		configFilePath = filepath.Join(dir, "config.yml")
+		bodyFilePath = filepath.Join(dir, "body.tmpl")
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	writeConfig := func() {
+		config := `
+notifications:
+  cf_org: test-org
+  cf_space: some-cf-space
+templates:
+  subject: "[{{.Product}}] {{.Subject}}"
+  overrides:
+  - severity: critical
+    body_file: ` + bodyFilePath + `
+`
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+	}
+
+	run := func(args ...string) *gexec.Session {
+		cmd := exec.Command(sendServiceAlertsBin, append([]string{"-config", configFilePath, "-product", "redis", "-subject", "down", "-content", "it broke", "-dry-run"}, args...)...)
+		session, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return session
+	}
+
+	It("renders the configured templates", func() {
+		Expect(ioutil.WriteFile(bodyFilePath, []byte("CRITICAL\n{{indent 2 .Content}}"), 0600)).To(Succeed())
+		writeConfig()
+
+		session := run()
+		Eventually(session, 5).Should(gexec.Exit(0))
+
+		var notification client.DryRunNotification
+		Expect(json.Unmarshal(session.Out.Contents(), &notification)).To(Succeed())
+		Expect(notification.Request.Subject).To(Equal("[redis] down"))
+		Expect(notification.Request.Text).To(Equal("CRITICAL\n  it broke"))
+	})
+
+	It("renders the occurrence time in the display timezone", func() {
+		config := `
+notifications:
+  cf_org: test-org
+  cf_space: some-cf-space
+templates:
+  timezone: Europe/London
+  timestamp_layout: "02 Jan 2006 15:04 MST"
+`
+		Expect(ioutil.WriteFile(configFilePath, []byte(config), 0600)).To(Succeed())
+
+		session := run("-occurred-at", "2016-07-01T12:00:00Z")
+		Eventually(session, 5).Should(gexec.Exit(0))
+
+		var notification client.DryRunNotification
+		Expect(json.Unmarshal(session.Out.Contents(), &notification)).To(Succeed())
+		Expect(notification.Request.Text).To(ContainSubstring("[Alert occurred at 01 Jul 2016 13:00 BST, sent at "))
+	})
+
+	It("fails at startup with the line of a broken template", func() {
+		Expect(ioutil.WriteFile(bodyFilePath, []byte("CRITICAL\n{{end}}\n"), 0600)).To(Succeed())
+		writeConfig()
+
+		session := run()
+		Eventually(session, 5).Should(gexec.Exit(5))
+		Expect(session.Err).To(gbytes.Say(`%s:2:`, regexp.QuoteMeta(bodyFilePath)))
+	})
+})